@@ -0,0 +1,232 @@
+package anatest
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	a "github.com/dalthon/ana"
+)
+
+// FakeRepository keeps tracked operations in memory, behaving as any other
+// repository does, so that code built on top of ana can be tested without a
+// database. Operations never lock each other out, so concurrent calls for a
+// running operation get ana.StillRunningError right away.
+type FakeRepository[P any, R any] struct {
+	mutex      sync.Mutex
+	clock      a.Clock
+	operations map[string]*a.TrackedOperation[P, R]
+	attempts   map[string]int64
+	written    []string
+}
+
+func NewFakeRepository[P any, R any](clock a.Clock) *FakeRepository[P, R] {
+	if clock == nil {
+		clock = a.SystemClock
+	}
+
+	return &FakeRepository[P, R]{
+		clock:      clock,
+		operations: map[string]*a.TrackedOperation[P, R]{},
+		attempts:   map[string]int64{},
+	}
+}
+
+func (repo *FakeRepository[P, R]) Clock() a.Clock {
+	return repo.clock
+}
+
+// Store saves given tracked operations as they are, replacing any other with
+// the same key and target.
+func (repo *FakeRepository[P, R]) Store(trackedOperations ...*a.TrackedOperation[P, R]) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	for _, trackedOperation := range trackedOperations {
+		repo.operations[fakeKey(trackedOperation.Key, trackedOperation.Target)] = copyTrackedOperation(trackedOperation)
+	}
+}
+
+// Written lists everything written through FakeContext.Write by operations
+// that succeeded, in the order they finished.
+func (repo *FakeRepository[P, R]) Written() []string {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	return append([]string{}, repo.written...)
+}
+
+func (repo *FakeRepository[P, R]) FetchOrStart(operation a.Operation[P, R, *FakeContext[P, R]]) *a.TrackedOperation[P, R] {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	return repo.fetchOrStart(operation)
+}
+
+func (repo *FakeRepository[P, R]) FetchOrStartBatch(operations []a.Operation[P, R, *FakeContext[P, R]]) []*a.TrackedOperation[P, R] {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	trackedOperations := make([]*a.TrackedOperation[P, R], len(operations))
+	for i, operation := range operations {
+		trackedOperations[i] = repo.fetchOrStart(operation)
+	}
+
+	return trackedOperations
+}
+
+func (repo *FakeRepository[P, R]) Lookup(key string, target string) *a.TrackedOperation[P, R] {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	trackedOperation, ok := repo.operations[fakeKey(key, target)]
+	if !ok {
+		return nil
+	}
+
+	return copyTrackedOperation(trackedOperation)
+}
+
+func (repo *FakeRepository[P, R]) NewSession(operation a.Operation[P, R, *FakeContext[P, R]]) *a.Session[P, R, *FakeContext[P, R]] {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	id := fakeKey(operation.Key(), operation.Target())
+	if trackedOperation, ok := repo.operations[id]; ok {
+		now := repo.clock.Now()
+		trackedOperation.Status = a.Running
+		trackedOperation.StartedAt = now
+		trackedOperation.Timeout = timeAfter(now, operation.Timeout())
+	}
+
+	repo.attempts[id] += 1
+
+	return a.NewSession(operation, &FakeContext[P, R]{
+		repository: repo,
+		key:        operation.Key(),
+		target:     operation.Target(),
+		Attempt:    repo.attempts[id],
+	})
+}
+
+func (repo *FakeRepository[P, R]) fetchOrStart(operation a.Operation[P, R, *FakeContext[P, R]]) *a.TrackedOperation[P, R] {
+	id := fakeKey(operation.Key(), operation.Target())
+	if trackedOperation, ok := repo.operations[id]; ok {
+		return copyTrackedOperation(trackedOperation)
+	}
+
+	now := repo.clock.Now()
+	trackedOperation := a.NewTrackedOperation[P, R](
+		a.Running,
+		operation.Key(),
+		operation.Target(),
+		operation.Payload(),
+		operation.ReferenceTime(),
+		now,
+		timeAfter(now, operation.Timeout()),
+		timeAfter(operation.ReferenceTime(), operation.Expiration()),
+		nil,
+		nil,
+	)
+	repo.operations[id] = trackedOperation
+
+	started := copyTrackedOperation(trackedOperation)
+	started.Status = a.Ready
+
+	return started
+}
+
+// FakeContext records outcomes on its FakeRepository, unless a newer attempt
+// took its operation over.
+type FakeContext[P any, R any] struct {
+	repository *FakeRepository[P, R]
+	key        string
+	target     string
+	pending    []string
+	Attempt    int64
+}
+
+// Write stands for anything written by an operation on its transaction, so it
+// is kept only if the operation succeeds.
+func (ctx *FakeContext[P, R]) Write(value string) {
+	ctx.pending = append(ctx.pending, value)
+}
+
+func (ctx *FakeContext[P, R]) Extend(timeout time.Duration) error {
+	return ctx.update(func(trackedOperation *a.TrackedOperation[P, R], now time.Time) {
+		if trackedOperation.Status == a.Running {
+			trackedOperation.Timeout = now.Add(timeout)
+		}
+	})
+}
+
+func (ctx *FakeContext[P, R]) Success(operation *a.TrackedOperation[P, R]) error {
+	if ctx.repository != nil && !operation.Expiration.IsZero() && ctx.repository.clock.Now().After(operation.Expiration) {
+		operation.Err = errors.New("Operation expired")
+		return ctx.Fail(operation)
+	}
+
+	return ctx.update(func(trackedOperation *a.TrackedOperation[P, R], now time.Time) {
+		trackedOperation.Status = a.Finished
+		trackedOperation.Payload = operation.Payload
+		trackedOperation.Result = operation.Result
+		trackedOperation.Err = nil
+		ctx.repository.written = append(ctx.repository.written, ctx.pending...)
+	})
+}
+
+func (ctx *FakeContext[P, R]) Fail(operation *a.TrackedOperation[P, R]) error {
+	ctx.pending = nil
+
+	return ctx.update(func(trackedOperation *a.TrackedOperation[P, R], now time.Time) {
+		trackedOperation.Status = a.Failed
+		trackedOperation.Payload = operation.Payload
+		trackedOperation.Result = nil
+		trackedOperation.Timeout = now
+		trackedOperation.Err = operation.Err
+	})
+}
+
+// CommitWork keeps everything written by operation so far.
+func (ctx *FakeContext[P, R]) CommitWork() error {
+	return ctx.update(func(trackedOperation *a.TrackedOperation[P, R], now time.Time) {
+		ctx.repository.written = append(ctx.repository.written, ctx.pending...)
+	})
+}
+
+func (ctx *FakeContext[P, R]) update(fn func(*a.TrackedOperation[P, R], time.Time)) error {
+	repo := ctx.repository
+	if repo == nil {
+		return errors.New("Context has no repository")
+	}
+
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	id := fakeKey(ctx.key, ctx.target)
+	trackedOperation, ok := repo.operations[id]
+	if !ok || repo.attempts[id] != ctx.Attempt {
+		return a.NewSupersededError(ctx.target, ctx.key)
+	}
+
+	fn(trackedOperation, repo.clock.Now())
+	return nil
+}
+
+func fakeKey(key string, target string) string {
+	return target + "\x00" + key
+}
+
+func copyTrackedOperation[P any, R any](trackedOperation *a.TrackedOperation[P, R]) *a.TrackedOperation[P, R] {
+	copied := *trackedOperation
+	return &copied
+}
+
+// timeAfter leaves tracked operations without deadline for zero durations.
+func timeAfter(base time.Time, duration time.Duration) time.Time {
+	if duration == time.Duration(0) {
+		return time.Time{}
+	}
+
+	return base.Add(duration)
+}
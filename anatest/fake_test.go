@@ -0,0 +1,49 @@
+package anatest
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	a "github.com/dalthon/ana"
+
+	"testing"
+)
+
+func TestFakeRepositorySuite(t *testing.T) {
+	RunRepositorySuite(t, func(t *testing.T) *Backend[*FakeContext[Payload, Result]] {
+		repo := NewFakeRepository[Payload, Result](nil)
+
+		return &Backend[*FakeContext[Payload, Result]]{
+			Repository: repo,
+			Write: func(ctx *FakeContext[Payload, Result], value string) error {
+				ctx.Write(value)
+				return nil
+			},
+			Written: func(value string) bool {
+				return slices.Contains(repo.Written(), value)
+			},
+		}
+	})
+}
+
+func TestFakeRepositoryFixtures(t *testing.T) {
+	repo := NewFakeRepository[Payload, Result](nil)
+	manager := a.New[Payload, Result, *FakeContext[Payload, Result]](repo)
+	repo.Store(
+		FinishedOperation[Payload, Result]("finished", suiteTarget, &Payload{"payload"}, &Result{"stored"}),
+		RunningOperation[Payload, Result]("running", suiteTarget, &Payload{"payload"}, time.Now().Add(time.Minute)),
+		FailedOperation[Payload, Result]("failed", suiteTarget, &Payload{"payload"}, errors.New("Something went wrong")),
+	)
+
+	result, err := manager.Call(suiteOperation[*FakeContext[Payload, Result]]("finished", succeed[*FakeContext[Payload, Result]]("result")))
+	assertResult(t, "stored", result, err)
+
+	_, err = manager.Call(suiteOperation[*FakeContext[Payload, Result]]("running", succeed[*FakeContext[Payload, Result]]("result")))
+	if !errors.Is(err, a.ErrStillRunning) {
+		t.Fatalf("Expected to have still running error, but got \"%v\"", err)
+	}
+
+	result, err = manager.Call(suiteOperation[*FakeContext[Payload, Result]]("failed", succeed[*FakeContext[Payload, Result]]("result")))
+	assertResult(t, "result", result, err)
+}
@@ -0,0 +1,358 @@
+package anatest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	a "github.com/dalthon/ana"
+)
+
+type Fault int
+
+const (
+	LatencyFault Fault = iota
+	ErrorFault
+	DropFault
+	CrashFault
+)
+
+func (fault Fault) String() string {
+	switch fault {
+	case LatencyFault:
+		return "latency"
+	case ErrorFault:
+		return "error"
+	case DropFault:
+		return "drop"
+	case CrashFault:
+		return "crash"
+	default:
+		return fmt.Sprintf("Fault(%d)", int(fault))
+	}
+}
+
+var ErrInjected = errors.New("Injected fault")
+
+type InjectedFaultError struct {
+	Fault  Fault
+	Method string
+	Target string
+	Key    string
+}
+
+func (err *InjectedFaultError) Error() string {
+	return fmt.Sprintf("Injected %v fault on %s of %s operation with key %s", err.Fault, err.Method, err.Target, err.Key)
+}
+
+func (err *InjectedFaultError) Is(target error) bool {
+	return target == ErrInjected
+}
+
+// FaultSchedule tells how often faults are injected. Every call draws from a
+// generator seeded with Seed, so that calls made in the same order get the
+// same faults on every run.
+type FaultSchedule struct {
+	Seed int64
+
+	// Latency is slept before calls drawn by LatencyRate.
+	Latency     time.Duration
+	LatencyRate float64
+
+	// ErrorRate, DropRate and CrashRate are chances of each fault, which are
+	// exclusive of each other, so they should add up to at most 1.
+	ErrorRate float64
+	DropRate  float64
+	CrashRate float64
+}
+
+// InjectedFault tells which fault was injected on which call.
+type InjectedFault struct {
+	Fault  Fault
+	Method string
+	Target string
+	Key    string
+}
+
+// CrashableSessionCtx is implemented by contexts able to commit operation
+// writes without recording operation outcome, which crashes require. CommitWork
+// leaves tracked operations running, as if their processes died right after.
+type CrashableSessionCtx interface {
+	CommitWork() error
+}
+
+// FaultyRepository wraps an IdempotencyRepository, injecting faults on its
+// calls as scheduled:
+//
+//   - latency, on every call;
+//   - errors, which FetchOrStart, FetchOrStartBatch, NewSession and Lookup
+//     panic with, as repositories do on infrastructure failures, while
+//     SessionCtx.Success and SessionCtx.Fail lose their commit and return them;
+//   - dropped commits, where SessionCtx.Success and SessionCtx.Fail lose their
+//     commit but return no error;
+//   - crashes, where SessionCtx.Success commits operation writes through
+//     CrashableSessionCtx, leaving its tracked operation running, and returns
+//     an error, as if its process died right after.
+//
+// Commits are lost by aborting contexts able to, or else by recording their
+// operations as failed, which rolls their writes back. Operations whose commits
+// were lost are then reported as running by this wrapper until they time out,
+// just as if their processes died, even though wrapped repository has those
+// recorded as failed on contexts unable to abort.
+type FaultyRepository[P any, R any, C a.SessionCtx[P, R]] struct {
+	repository a.IdempotencyRepository[P, R, C]
+	schedule   FaultSchedule
+	mutex      sync.Mutex
+	random     *rand.Rand
+	injected   []InjectedFault
+	running    map[string]*a.TrackedOperation[P, R]
+}
+
+func NewFaultyRepository[P any, R any, C a.SessionCtx[P, R]](repository a.IdempotencyRepository[P, R, C], schedule FaultSchedule) *FaultyRepository[P, R, C] {
+	if _, ok := any(*new(C)).(CrashableSessionCtx); !ok && schedule.CrashRate > 0 {
+		panic("Crashes require contexts implementing CrashableSessionCtx")
+	}
+
+	return &FaultyRepository[P, R, C]{
+		repository: repository,
+		schedule:   schedule,
+		random:     rand.New(rand.NewSource(schedule.Seed)),
+		running:    map[string]*a.TrackedOperation[P, R]{},
+	}
+}
+
+// Clock forwards to wrapped repository.
+func (repo *FaultyRepository[P, R, C]) Clock() a.Clock {
+	if provider, ok := repo.repository.(a.ClockProvider); ok {
+		return provider.Clock()
+	}
+
+	return a.SystemClock
+}
+
+// Injected lists every fault injected so far, in the order they were.
+func (repo *FaultyRepository[P, R, C]) Injected() []InjectedFault {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	return append([]InjectedFault{}, repo.injected...)
+}
+
+func (repo *FaultyRepository[P, R, C]) FetchOrStart(operation a.Operation[P, R, C]) *a.TrackedOperation[P, R] {
+	repo.injectCall("FetchOrStart", operation.Target(), operation.Key())
+
+	if trackedOperation := repo.lost(operation.Key(), operation.Target()); trackedOperation != nil {
+		return trackedOperation
+	}
+
+	return repo.repository.FetchOrStart(operation)
+}
+
+func (repo *FaultyRepository[P, R, C]) FetchOrStartBatch(operations []a.Operation[P, R, C]) []*a.TrackedOperation[P, R] {
+	repo.injectCall("FetchOrStartBatch", "", "")
+
+	var trackedOperations []*a.TrackedOperation[P, R]
+	if batchRepository, ok := repo.repository.(a.BatchIdempotencyRepository[P, R, C]); ok {
+		trackedOperations = batchRepository.FetchOrStartBatch(operations)
+	} else {
+		trackedOperations = make([]*a.TrackedOperation[P, R], len(operations))
+		for i, operation := range operations {
+			trackedOperations[i] = repo.repository.FetchOrStart(operation)
+		}
+	}
+
+	for i, operation := range operations {
+		if trackedOperation := repo.lost(operation.Key(), operation.Target()); trackedOperation != nil {
+			trackedOperations[i] = trackedOperation
+		}
+	}
+
+	return trackedOperations
+}
+
+func (repo *FaultyRepository[P, R, C]) Lookup(key string, target string) *a.TrackedOperation[P, R] {
+	repo.injectCall("Lookup", target, key)
+
+	if trackedOperation := repo.lost(key, target); trackedOperation != nil {
+		return trackedOperation
+	}
+
+	if lookupRepository, ok := repo.repository.(a.LookupRepository[P, R]); ok {
+		return lookupRepository.Lookup(key, target)
+	}
+
+	return nil
+}
+
+// SupportsLookup tells whether wrapped repository supports lookups.
+func (repo *FaultyRepository[P, R, C]) SupportsLookup() bool {
+	if supporter, ok := repo.repository.(a.LookupSupporter); ok {
+		return supporter.SupportsLookup()
+	}
+
+	_, ok := repo.repository.(a.LookupRepository[P, R])
+	return ok
+}
+
+func (repo *FaultyRepository[P, R, C]) NewSession(operation a.Operation[P, R, C]) *a.Session[P, R, C] {
+	repo.injectCall("NewSession", operation.Target(), operation.Key())
+
+	session := repo.repository.NewSession(operation)
+
+	repo.mutex.Lock()
+	delete(repo.running, fakeKey(operation.Key(), operation.Target()))
+	repo.mutex.Unlock()
+
+	return session.WithRecorder(&faultyRecorder[P, R, C]{
+		repository: repo,
+		ctx:        session.Context,
+		timeout:    operation.Timeout(),
+	})
+}
+
+// injectCall panics with injected errors, as repositories do on infrastructure
+// failures.
+func (repo *FaultyRepository[P, R, C]) injectCall(method string, target string, key string) {
+	if fault, ok := repo.inject(method, target, key, ErrorFault); ok {
+		panic(&InjectedFaultError{fault, method, target, key})
+	}
+}
+
+// inject sleeps and returns the fault drawn for a call, if it is one of given
+// faults. Every call draws as much, so that schedules do not drift.
+func (repo *FaultyRepository[P, R, C]) inject(method string, target string, key string, faults ...Fault) (Fault, bool) {
+	repo.mutex.Lock()
+	latency := repo.random.Float64() < repo.schedule.LatencyRate
+	draw := repo.random.Float64()
+
+	fault, ok := repo.schedule.fault(draw)
+	if ok && !containsFault(faults, fault) {
+		ok = false
+	}
+
+	if latency {
+		repo.injected = append(repo.injected, InjectedFault{LatencyFault, method, target, key})
+	}
+
+	if ok {
+		repo.injected = append(repo.injected, InjectedFault{fault, method, target, key})
+	}
+	repo.mutex.Unlock()
+
+	if latency {
+		time.Sleep(repo.schedule.Latency)
+	}
+
+	return fault, ok
+}
+
+func (repo *FaultyRepository[P, R, C]) lost(key string, target string) *a.TrackedOperation[P, R] {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	trackedOperation, ok := repo.running[fakeKey(key, target)]
+	if !ok {
+		return nil
+	}
+
+	return copyTrackedOperation(trackedOperation)
+}
+
+func (repo *FaultyRepository[P, R, C]) lose(trackedOperation *a.TrackedOperation[P, R], timeout time.Duration) {
+	running := copyTrackedOperation(trackedOperation)
+	running.Status = a.Running
+	running.Timeout = timeAfter(trackedOperation.StartedAt, timeout)
+	running.Result = nil
+	running.Err = nil
+
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.running[fakeKey(trackedOperation.Key, trackedOperation.Target)] = running
+}
+
+func (schedule *FaultSchedule) fault(draw float64) (Fault, bool) {
+	if draw < schedule.ErrorRate {
+		return ErrorFault, true
+	}
+
+	if draw < schedule.ErrorRate+schedule.DropRate {
+		return DropFault, true
+	}
+
+	if draw < schedule.ErrorRate+schedule.DropRate+schedule.CrashRate {
+		return CrashFault, true
+	}
+
+	return LatencyFault, false
+}
+
+func containsFault(faults []Fault, fault Fault) bool {
+	for _, candidate := range faults {
+		if candidate == fault {
+			return true
+		}
+	}
+
+	return false
+}
+
+// faultyRecorder records session outcomes on wrapped context, unless a fault
+// is injected on the way.
+type faultyRecorder[P any, R any, C a.SessionCtx[P, R]] struct {
+	repository *FaultyRepository[P, R, C]
+	ctx        C
+	timeout    time.Duration
+}
+
+func (recorder *faultyRecorder[P, R, C]) Success(operation *a.TrackedOperation[P, R]) error {
+	fault, ok := recorder.repository.inject("Success", operation.Target, operation.Key, ErrorFault, DropFault, CrashFault)
+	if !ok {
+		return recorder.ctx.Success(operation)
+	}
+
+	if fault != CrashFault {
+		return recorder.lose(fault, "Success", operation)
+	}
+
+	if err := any(recorder.ctx).(CrashableSessionCtx).CommitWork(); err != nil {
+		return err
+	}
+
+	return &InjectedFaultError{fault, "Success", operation.Target, operation.Key}
+}
+
+func (recorder *faultyRecorder[P, R, C]) Fail(operation *a.TrackedOperation[P, R]) error {
+	fault, ok := recorder.repository.inject("Fail", operation.Target, operation.Key, ErrorFault, DropFault)
+	if !ok {
+		return recorder.ctx.Fail(operation)
+	}
+
+	return recorder.lose(fault, "Fail", operation)
+}
+
+func (recorder *faultyRecorder[P, R, C]) lose(fault Fault, method string, operation *a.TrackedOperation[P, R]) error {
+	injected := &InjectedFaultError{fault, method, operation.Target, operation.Key}
+
+	var err error
+	if retryable, ok := any(recorder.ctx).(a.RetryableSessionCtx); ok {
+		err = retryable.Abort()
+	} else {
+		failed := copyTrackedOperation(operation)
+		failed.Err = injected
+		err = recorder.ctx.Fail(failed)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	recorder.repository.lose(operation, recorder.timeout)
+	if fault == DropFault {
+		return nil
+	}
+
+	return injected
+}
@@ -0,0 +1,138 @@
+package anatest
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"time"
+
+	a "github.com/dalthon/ana"
+
+	"testing"
+)
+
+type fakeCtx = *FakeContext[Payload, Result]
+
+func TestFaultyRepositorySuite(t *testing.T) {
+	RunRepositorySuite(t, func(t *testing.T) *Backend[fakeCtx] {
+		backing := NewFakeRepository[Payload, Result](nil)
+
+		return &Backend[fakeCtx]{
+			Repository: NewFaultyRepository[Payload, Result, fakeCtx](backing, FaultSchedule{Latency: time.Millisecond, LatencyRate: 1}),
+			Write: func(ctx fakeCtx, value string) error {
+				ctx.Write(value)
+				return nil
+			},
+			Written: func(value string) bool {
+				return slices.Contains(backing.Written(), value)
+			},
+		}
+	})
+}
+
+func TestFaultyRepositorySchedule(t *testing.T) {
+	schedule := FaultSchedule{Seed: 42, LatencyRate: 0.5, DropRate: 0.3, CrashRate: 0.3}
+	run := func() []InjectedFault {
+		repo := NewFaultyRepository[Payload, Result, fakeCtx](NewFakeRepository[Payload, Result](nil), schedule)
+		manager := a.New[Payload, Result, fakeCtx](repo)
+		for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+			manager.Call(suiteOperation[fakeCtx](key, succeed[fakeCtx]("result")))
+		}
+
+		return repo.Injected()
+	}
+
+	injected := run()
+	if len(injected) == 0 || !reflect.DeepEqual(injected, run()) {
+		t.Fatalf("Expected to inject the same faults on every run, but got %+v", injected)
+	}
+}
+
+func TestFaultyRepositorySupportsLookup(t *testing.T) {
+	repo := NewFaultyRepository[Payload, Result, fakeCtx](NewFakeRepository[Payload, Result](nil), FaultSchedule{})
+	if !repo.SupportsLookup() {
+		t.Fatalf("Expected to support lookups of wrapped repository")
+	}
+}
+
+func TestFaultyRepositoryUncrashable(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Expected to panic")
+		}
+	}()
+
+	NewFaultyRepository[Payload, Result, *uncrashableCtx](nil, FaultSchedule{CrashRate: 1})
+}
+
+type uncrashableCtx struct{}
+
+func (ctx *uncrashableCtx) Success(*a.TrackedOperation[Payload, Result]) error { return nil }
+func (ctx *uncrashableCtx) Fail(*a.TrackedOperation[Payload, Result]) error    { return nil }
+
+func TestFaultyRepositoryErrors(t *testing.T) {
+	repo := NewFaultyRepository[Payload, Result, fakeCtx](NewFakeRepository[Payload, Result](nil), FaultSchedule{ErrorRate: 1})
+
+	defer func() {
+		if err, ok := recover().(error); !ok || !errors.Is(err, ErrInjected) {
+			t.Fatalf("Expected to panic with injected error, but got \"%v\"", err)
+		}
+	}()
+
+	repo.FetchOrStart(suiteOperation[fakeCtx]("key", succeed[fakeCtx]("result")))
+	t.Fatalf("Expected to panic")
+}
+
+func TestFaultyRepositoryDroppedCommit(t *testing.T) {
+	backing := NewFakeRepository[Payload, Result](nil)
+	manager := a.New[Payload, Result, fakeCtx](NewFaultyRepository[Payload, Result, fakeCtx](backing, FaultSchedule{DropRate: 1}))
+	operation := suiteOperation[fakeCtx]("key", func(ctx fakeCtx) (*Result, error) {
+		ctx.Write("written")
+		return &Result{"result"}, nil
+	}).WithTimeout(SuiteDelay)
+
+	result, err := manager.Call(operation)
+	assertResult(t, "result", result, err)
+	if len(backing.Written()) != 0 {
+		t.Fatalf("Expected to have dropped writes, but got %v", backing.Written())
+	}
+
+	if _, err := manager.Call(operation); !errors.Is(err, a.ErrStillRunning) {
+		t.Fatalf("Expected to have still running error, but got \"%v\"", err)
+	}
+
+	time.Sleep(SuiteDelay + SuiteDelay/2)
+	result, err = manager.Call(operation)
+	assertResult(t, "result", result, err)
+}
+
+func TestFaultyRepositoryCrash(t *testing.T) {
+	backing := NewFakeRepository[Payload, Result](nil)
+	repo := NewFaultyRepository[Payload, Result, fakeCtx](backing, FaultSchedule{CrashRate: 1})
+	manager := a.New[Payload, Result, fakeCtx](repo)
+	operation := suiteOperation[fakeCtx]("key", func(ctx fakeCtx) (*Result, error) {
+		ctx.Write("written")
+		return &Result{"result"}, nil
+	}).WithTimeout(SuiteDelay)
+
+	var injectedErr *InjectedFaultError
+	if _, err := manager.Call(operation); !errors.As(err, &injectedErr) || injectedErr.Fault != CrashFault {
+		t.Fatalf("Expected to have crash error, but got \"%v\"", err)
+	}
+
+	assertStatus(t, backing.Lookup("key", suiteTarget), a.Running)
+	if written := backing.Written(); !reflect.DeepEqual(written, []string{"written"}) {
+		t.Fatalf("Expected to have kept crashed writes, but got %v", written)
+	}
+
+	if _, err := manager.Call(operation); !errors.Is(err, a.ErrStillRunning) {
+		t.Fatalf("Expected to have still running error, but got \"%v\"", err)
+	}
+
+	time.Sleep(SuiteDelay + SuiteDelay/2)
+	manager.Call(operation)
+
+	if written := backing.Written(); !reflect.DeepEqual(written, []string{"written", "written"}) {
+		t.Fatalf("Expected to have written twice, but got %v", written)
+	}
+}
@@ -0,0 +1,52 @@
+package anatest
+
+import (
+	"time"
+
+	a "github.com/dalthon/ana"
+)
+
+// FixtureExpiration is how long after now fixtures expire.
+const FixtureExpiration time.Duration = time.Hour
+
+// RunningOperation builds a tracked operation started now, as repositories
+// return it while some attempt is running it until given timeout.
+func RunningOperation[P any, R any](key string, target string, payload *P, timeout time.Time) *a.TrackedOperation[P, R] {
+	return newFixture[P, R](a.Running, key, target, payload, timeout, nil, nil)
+}
+
+// FinishedOperation builds a tracked operation that finished with result.
+func FinishedOperation[P any, R any](key string, target string, payload *P, result *R) *a.TrackedOperation[P, R] {
+	return newFixture[P, R](a.Finished, key, target, payload, time.Now(), result, nil)
+}
+
+// FailedOperation builds a tracked operation that failed with err, so that it
+// may run again.
+func FailedOperation[P any, R any](key string, target string, payload *P, err error) *a.TrackedOperation[P, R] {
+	return newFixture[P, R](a.Failed, key, target, payload, time.Now(), nil, err)
+}
+
+func newFixture[P any, R any](
+	status a.TrackedOperationStatus,
+	key string,
+	target string,
+	payload *P,
+	timeout time.Time,
+	result *R,
+	err error,
+) *a.TrackedOperation[P, R] {
+	now := time.Now()
+
+	return a.NewTrackedOperation(
+		status,
+		key,
+		target,
+		payload,
+		now,
+		now,
+		timeout,
+		now.Add(FixtureExpiration),
+		result,
+		err,
+	)
+}
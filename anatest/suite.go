@@ -0,0 +1,415 @@
+package anatest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	a "github.com/dalthon/ana"
+)
+
+// SuiteDelay is how long operations time out or expire on suite cases relying
+// on them to, and is slept for a bit longer than that.
+const SuiteDelay time.Duration = 200 * time.Millisecond
+
+const suiteTarget string = "suite"
+
+type Payload struct {
+	Value string
+}
+
+type Result struct {
+	Value string
+}
+
+// Backend is what RunRepositorySuite exercises. When Write and Written are
+// given, the suite also checks that whatever operations write on their
+// contexts is kept only when they succeed.
+type Backend[C a.SessionCtx[Payload, Result]] struct {
+	Repository a.IdempotencyRepository[Payload, Result, C]
+	Write      func(ctx C, value string) error
+	Written    func(value string) bool
+}
+
+// RunRepositorySuite checks that repositories given by factory, which must be
+// empty, behave as every repository must. Each case gets its own repository.
+func RunRepositorySuite[C a.SessionCtx[Payload, Result]](t *testing.T, factory func(t *testing.T) *Backend[C]) {
+	cases := []struct {
+		name string
+		run  func(*testing.T, *Backend[C])
+	}{
+		{"FetchOrStart", suiteFetchOrStart[C]},
+		{"FetchOrStartBatch", suiteFetchOrStartBatch[C]},
+		{"CallOnce", suiteCallOnce[C]},
+		{"FailedOperationsRunAgain", suiteFailedOperationsRunAgain[C]},
+		{"RollsBackFailures", suiteRollsBackFailures[C]},
+		{"StillRunning", suiteStillRunning[C]},
+		{"TimedOutOperationsRunAgain", suiteTimedOutOperationsRunAgain[C]},
+		{"LateSuccess", suiteLateSuccess[C]},
+		{"Expiration", suiteExpiration[C]},
+		{"ExpiredWhileRunning", suiteExpiredWhileRunning[C]},
+		{"Batch", suiteBatch[C]},
+		{"Concurrency", suiteConcurrency[C]},
+		{"Crash", suiteCrash[C]},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.run(t, factory(t))
+		})
+	}
+}
+
+func suiteFetchOrStart[C a.SessionCtx[Payload, Result]](t *testing.T, backend *Backend[C]) {
+	referenceTime := time.Now().UTC().Truncate(time.Second)
+	operation := suiteOperation[C]("key", succeed[C]("result")).WithReferenceTime(referenceTime)
+
+	trackedOperation := backend.Repository.FetchOrStart(operation)
+	assertStatus(t, trackedOperation, a.Ready)
+	if trackedOperation.Key != "key" || trackedOperation.Target != suiteTarget || trackedOperation.Payload.Value != "payload" {
+		t.Fatalf("Expected to have started given operation, but got %+v", trackedOperation)
+	}
+
+	if !trackedOperation.ReferenceTime.Equal(referenceTime) || trackedOperation.Result != nil || trackedOperation.Err != nil {
+		t.Fatalf("Expected to have started operation with given reference time, but got %+v", trackedOperation)
+	}
+
+	anotherTrackedOperation := backend.Repository.FetchOrStart(operation)
+	assertStatus(t, anotherTrackedOperation, a.Running)
+	if !anotherTrackedOperation.StartedAt.Equal(trackedOperation.StartedAt) ||
+		!anotherTrackedOperation.Timeout.Equal(trackedOperation.Timeout) ||
+		!anotherTrackedOperation.Expiration.Equal(trackedOperation.Expiration) {
+		t.Fatalf("Expected to have running operation as started, %+v, but got %+v", trackedOperation, anotherTrackedOperation)
+	}
+}
+
+func suiteFetchOrStartBatch[C a.SessionCtx[Payload, Result]](t *testing.T, backend *Backend[C]) {
+	repository, ok := backend.Repository.(a.BatchIdempotencyRepository[Payload, Result, C])
+	if !ok {
+		t.Skip("Backend can not start operations in batches")
+	}
+
+	repository.FetchOrStart(suiteOperation[C]("started", succeed[C]("result")))
+	trackedOperations := repository.FetchOrStartBatch([]a.Operation[Payload, Result, C]{
+		suiteOperation[C]("new", succeed[C]("result")),
+		suiteOperation[C]("started", succeed[C]("result")),
+		suiteOperation[C]("another", succeed[C]("result")),
+		suiteOperation[C]("new", succeed[C]("result")),
+	})
+
+	if len(trackedOperations) != 4 {
+		t.Fatalf("Expected to have 4 tracked operations, but got %d", len(trackedOperations))
+	}
+
+	for i, expected := range []struct {
+		key    string
+		status a.TrackedOperationStatus
+	}{{"new", a.Ready}, {"started", a.Running}, {"another", a.Ready}, {"new", a.Running}} {
+		assertStatus(t, trackedOperations[i], expected.status)
+		if trackedOperations[i].Key != expected.key {
+			t.Fatalf("Expected to have \"%s\" tracked operation at %d, but got %+v", expected.key, i, trackedOperations[i])
+		}
+	}
+}
+
+func suiteCallOnce[C a.SessionCtx[Payload, Result]](t *testing.T, backend *Backend[C]) {
+	manager := a.New(backend.Repository)
+
+	var calls atomic.Int64
+	operation := suiteOperation[C]("key", func(C) (*Result, error) {
+		calls.Add(1)
+		return &Result{"result"}, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		result, err := manager.Call(operation)
+		assertResult(t, "result", result, err)
+	}
+
+	if calls.Load() != 1 {
+		t.Fatalf("Expected operation to run once, but it ran %d times", calls.Load())
+	}
+
+	if trackedOperation, ok := lookup(backend, "key"); ok {
+		assertStatus(t, trackedOperation, a.Finished)
+		if trackedOperation.Result == nil || trackedOperation.Result.Value != "result" {
+			t.Fatalf("Expected to have looked \"result\" up, but got %+v", trackedOperation.Result)
+		}
+	}
+}
+
+func suiteFailedOperationsRunAgain[C a.SessionCtx[Payload, Result]](t *testing.T, backend *Backend[C]) {
+	manager := a.New(backend.Repository)
+
+	_, err := manager.Call(suiteOperation[C]("key", fail[C]("Something went wrong")))
+	if err == nil || err.Error() != "Something went wrong" {
+		t.Fatalf("Expected to have \"Something went wrong\" error, but got \"%v\"", err)
+	}
+
+	if trackedOperation, ok := lookup(backend, "key"); ok {
+		assertStatus(t, trackedOperation, a.Failed)
+		if trackedOperation.Err == nil || trackedOperation.Err.Error() != "Something went wrong" {
+			t.Fatalf("Expected to have looked \"Something went wrong\" up, but got \"%v\"", trackedOperation.Err)
+		}
+	}
+
+	result, err := manager.Call(suiteOperation[C]("key", succeed[C]("result")))
+	assertResult(t, "result", result, err)
+}
+
+func suiteRollsBackFailures[C a.SessionCtx[Payload, Result]](t *testing.T, backend *Backend[C]) {
+	if backend.Write == nil || backend.Written == nil {
+		t.Skip("Backend can not write on contexts")
+	}
+
+	manager := a.New(backend.Repository)
+	write := func(value string, fn func(C) (*Result, error)) func(C) (*Result, error) {
+		return func(ctx C) (*Result, error) {
+			if err := backend.Write(ctx, value); err != nil {
+				return nil, err
+			}
+
+			return fn(ctx)
+		}
+	}
+
+	result, err := manager.Call(suiteOperation[C]("kept", write("kept", succeed[C]("result"))))
+	assertResult(t, "result", result, err)
+	manager.Call(suiteOperation[C]("failed", write("failed", fail[C]("Something went wrong"))))
+	manager.Call(suiteOperation[C]("panicked", write("panicked", func(C) (*Result, error) { panic("Boom!") })))
+
+	for value, expected := range map[string]bool{"kept": true, "failed": false, "panicked": false} {
+		if backend.Written(value) != expected {
+			t.Fatalf("Expected \"%s\" to be written: %v, but it was not", value, expected)
+		}
+	}
+
+	if trackedOperation, ok := lookup(backend, "panicked"); ok {
+		assertStatus(t, trackedOperation, a.Failed)
+	}
+}
+
+func suiteStillRunning[C a.SessionCtx[Payload, Result]](t *testing.T, backend *Backend[C]) {
+	manager := a.New(backend.Repository)
+	backend.Repository.FetchOrStart(suiteOperation[C]("key", succeed[C]("abandoned")))
+
+	_, err := manager.Call(suiteOperation[C]("key", succeed[C]("result")))
+	if !errors.Is(err, a.ErrStillRunning) {
+		t.Fatalf("Expected to have still running error, but got \"%v\"", err)
+	}
+}
+
+func suiteTimedOutOperationsRunAgain[C a.SessionCtx[Payload, Result]](t *testing.T, backend *Backend[C]) {
+	manager := a.New(backend.Repository)
+	operation := suiteOperation[C]("key", succeed[C]("result")).WithTimeout(SuiteDelay)
+
+	backend.Repository.FetchOrStart(operation)
+	time.Sleep(SuiteDelay + SuiteDelay/2)
+
+	result, err := manager.Call(operation)
+	assertResult(t, "result", result, err)
+}
+
+// Operations outliving their timeout either had it extended by heartbeats, and
+// succeed, or fail with timeout errors, but are recorded just as they return.
+func suiteLateSuccess[C a.SessionCtx[Payload, Result]](t *testing.T, backend *Backend[C]) {
+	manager := a.New(backend.Repository)
+	result, err := manager.Call(suiteOperation[C]("key", func(ctx C) (*Result, error) {
+		if backend.Write != nil {
+			if err := backend.Write(ctx, "late"); err != nil {
+				return nil, err
+			}
+		}
+
+		time.Sleep(SuiteDelay + SuiteDelay/2)
+		return &Result{"result"}, nil
+	}).WithTimeout(SuiteDelay))
+
+	status := a.Finished
+	if err != nil {
+		if result != nil || !errors.Is(err, a.ErrTimeout) {
+			t.Fatalf("Expected to have either result or timeout error, but got %+v and \"%v\"", result, err)
+		}
+
+		status = a.Failed
+	} else {
+		assertResult(t, "result", result, err)
+	}
+
+	if backend.Written != nil && backend.Written("late") != (err == nil) {
+		t.Fatalf("Expected \"late\" to be written: %v, but it was not", err == nil)
+	}
+
+	if trackedOperation, ok := lookup(backend, "key"); ok {
+		assertStatus(t, trackedOperation, status)
+	}
+}
+
+func suiteExpiration[C a.SessionCtx[Payload, Result]](t *testing.T, backend *Backend[C]) {
+	manager := a.New(backend.Repository)
+	operation := suiteOperation[C]("key", succeed[C]("result")).WithExpiration(SuiteDelay)
+
+	backend.Repository.FetchOrStart(operation)
+	time.Sleep(SuiteDelay + SuiteDelay/2)
+
+	if _, err := manager.Call(operation); !errors.Is(err, a.ErrExpired) {
+		t.Fatalf("Expected to have expiration error, but got \"%v\"", err)
+	}
+}
+
+func suiteExpiredWhileRunning[C a.SessionCtx[Payload, Result]](t *testing.T, backend *Backend[C]) {
+	manager := a.New(backend.Repository)
+	operation := suiteOperation[C]("key", func(C) (*Result, error) {
+		time.Sleep(SuiteDelay + SuiteDelay/2)
+		return &Result{"result"}, nil
+	}).WithExpiration(SuiteDelay)
+
+	manager.Call(operation)
+
+	if trackedOperation, ok := lookup(backend, "key"); ok {
+		assertStatus(t, trackedOperation, a.Failed)
+	}
+}
+
+func suiteBatch[C a.SessionCtx[Payload, Result]](t *testing.T, backend *Backend[C]) {
+	manager := a.New(backend.Repository)
+	result, err := manager.Call(suiteOperation[C]("finished", succeed[C]("finished")))
+	assertResult(t, "finished", result, err)
+	backend.Repository.FetchOrStart(suiteOperation[C]("running", succeed[C]("abandoned")))
+
+	results, errs := manager.CallBatch([]a.Operation[Payload, Result, C]{
+		suiteOperation[C]("finished", succeed[C]("result")),
+		suiteOperation[C]("running", succeed[C]("result")),
+		suiteOperation[C]("new", succeed[C]("result")),
+	})
+
+	assertResult(t, "finished", results[0], errs[0])
+	if !errors.Is(errs[1], a.ErrStillRunning) {
+		t.Fatalf("Expected to have still running error, but got \"%v\"", errs[1])
+	}
+	assertResult(t, "result", results[2], errs[2])
+}
+
+// Some repositories make concurrent calls wait for running operations, while
+// others tell them right away that operations are still running, but every
+// one of them runs operations only once.
+func suiteConcurrency[C a.SessionCtx[Payload, Result]](t *testing.T, backend *Backend[C]) {
+	manager := a.New(backend.Repository)
+
+	var calls atomic.Int64
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result, err := manager.Call(suiteOperation[C]("key", func(C) (*Result, error) {
+				calls.Add(1)
+				time.Sleep(SuiteDelay / 4)
+				return &Result{"result"}, nil
+			}))
+
+			if err == nil && (result == nil || result.Value != "result") {
+				err = fmt.Errorf("Expected to have \"result\" result, but got %+v", result)
+			}
+
+			if err != nil && !errors.Is(err, a.ErrStillRunning) {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	if calls.Load() != 1 {
+		t.Fatalf("Expected operation to run once, but it ran %d times", calls.Load())
+	}
+}
+
+// Crashed operations keep their writes, but are left running until they time
+// out, as if their processes died right after committing them.
+func suiteCrash[C a.SessionCtx[Payload, Result]](t *testing.T, backend *Backend[C]) {
+	if _, ok := any(*new(C)).(CrashableSessionCtx); !ok {
+		t.Skip("Backend contexts can not crash")
+	}
+
+	manager := a.New[Payload, Result, C](NewFaultyRepository[Payload, Result, C](backend.Repository, FaultSchedule{CrashRate: 1}))
+	operation := suiteOperation[C]("key", func(ctx C) (*Result, error) {
+		if backend.Write != nil {
+			if err := backend.Write(ctx, "crashed"); err != nil {
+				return nil, err
+			}
+		}
+
+		return &Result{"result"}, nil
+	})
+
+	if _, err := manager.Call(operation); !errors.Is(err, ErrInjected) {
+		t.Fatalf("Expected to have injected error, but got \"%v\"", err)
+	}
+
+	if backend.Written != nil && !backend.Written("crashed") {
+		t.Fatalf("Expected \"crashed\" to be written, but it was not")
+	}
+
+	if trackedOperation, ok := lookup(backend, "key"); ok {
+		assertStatus(t, trackedOperation, a.Running)
+	}
+
+	if _, err := a.New(backend.Repository).Call(operation); !errors.Is(err, a.ErrStillRunning) {
+		t.Fatalf("Expected to have still running error, but got \"%v\"", err)
+	}
+}
+
+func suiteOperation[C a.SessionCtx[Payload, Result]](key string, fn func(C) (*Result, error)) *a.FuncOperation[Payload, Result, C] {
+	return a.NewOperation[Payload, Result, C](key, suiteTarget, fn).
+		WithPayload(&Payload{"payload"}).
+		WithTimeout(time.Minute).
+		WithExpiration(time.Hour)
+}
+
+func succeed[C a.SessionCtx[Payload, Result]](value string) func(C) (*Result, error) {
+	return func(C) (*Result, error) {
+		return &Result{value}, nil
+	}
+}
+
+func fail[C a.SessionCtx[Payload, Result]](message string) func(C) (*Result, error) {
+	return func(C) (*Result, error) {
+		return nil, errors.New(message)
+	}
+}
+
+func lookup[C a.SessionCtx[Payload, Result]](backend *Backend[C], key string) (*a.TrackedOperation[Payload, Result], bool) {
+	repository, ok := backend.Repository.(a.LookupRepository[Payload, Result])
+	if !ok {
+		return nil, false
+	}
+
+	return repository.Lookup(key, suiteTarget), true
+}
+
+func assertStatus(t *testing.T, trackedOperation *a.TrackedOperation[Payload, Result], status a.TrackedOperationStatus) {
+	t.Helper()
+
+	if trackedOperation == nil || trackedOperation.Status != status {
+		t.Fatalf("Expected to have tracked operation with status %d, but got %+v", status, trackedOperation)
+	}
+}
+
+func assertResult(t *testing.T, expected string, result *Result, err error) {
+	t.Helper()
+
+	if err != nil || result == nil || result.Value != expected {
+		t.Fatalf("Expected to have \"%s\" result, but got %+v and \"%v\"", expected, result, err)
+	}
+}
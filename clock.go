@@ -0,0 +1,56 @@
+package ana
+
+import (
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockProvider is implemented by repositories with their own notion of time,
+// which managers use by default for consistent decisions. Repository wrappers
+// implement it too, returning the clock of wrapped repositories, or SystemClock
+// when they have none, so that managers keep following them.
+type ClockProvider interface {
+	Clock() Clock
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+var SystemClock Clock = systemClock{}
+
+type FakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (clock *FakeClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	return clock.now
+}
+
+func (clock *FakeClock) Set(now time.Time) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	clock.now = now
+}
+
+func (clock *FakeClock) Advance(duration time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	clock.now = clock.now.Add(duration)
+}
@@ -0,0 +1,137 @@
+package ana
+
+import (
+	"testing"
+	"time"
+)
+
+type clockRepository struct {
+	*trackedOperationRepository
+	clock Clock
+}
+
+func (repo *clockRepository) Clock() Clock {
+	return repo.clock
+}
+
+func TestFakeClock(t *testing.T) {
+	reference := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(reference)
+
+	if !clock.Now().Equal(reference) {
+		t.Fatalf("Expected clock to be at %v, but got %v", reference, clock.Now())
+	}
+
+	clock.Advance(5 * time.Second)
+	if !clock.Now().Equal(reference.Add(5 * time.Second)) {
+		t.Fatalf("Expected clock to be at %v, but got %v", reference.Add(5*time.Second), clock.Now())
+	}
+
+	clock.Set(reference)
+	if !clock.Now().Equal(reference) {
+		t.Fatalf("Expected clock to be at %v, but got %v", reference, clock.Now())
+	}
+}
+
+func TestFakeClockExpiration(t *testing.T) {
+	reference := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(reference)
+	manager := New(newEmptyRepository(), WithClock(clock))
+	operation := newMockedOperation(
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		reference,
+		5*time.Second,
+		10*time.Second,
+		newMockedResultFn("result"),
+	)
+
+	clock.Advance(9 * time.Second)
+	result, err := manager.Call(operation)
+	if err != nil || result == nil || result.result != "result" {
+		t.Fatalf("Expected to have \"result\" result, but got \"%v\" and \"%v\"", result, err)
+	}
+
+	clock.Advance(2 * time.Second)
+	result, err = manager.Call(operation)
+
+	exptectedErr := newExpirationError("target", "key")
+	if err == nil || err.Error() != exptectedErr.Error() {
+		t.Fatalf("Expected to have \"%v\" error, but got \"%v\"", exptectedErr, err)
+	}
+
+	if result != nil {
+		t.Fatalf("Expected to have no result, but got \"%s\"", result.result)
+	}
+}
+
+func TestRepositoryClock(t *testing.T) {
+	reference := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(reference)
+	trackedOperation := NewTrackedOperation(
+		Running,
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		reference,
+		reference,
+		reference.Add(5*time.Second),
+		reference.Add(10*time.Second),
+		(*mockedResult)(nil),
+		nil,
+	)
+	manager := New[mockedPayload, mockedResult, *mockedCtx](&clockRepository{
+		trackedOperationRepository: newTrackedOperationRepository(trackedOperation),
+		clock:                      clock,
+	})
+	operation := newMockedOperation(
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		reference,
+		5*time.Second,
+		10*time.Second,
+		newMockedResultFn("result"),
+	)
+
+	clock.Advance(time.Second)
+	_, err := manager.Call(operation)
+
+	exptectedErr := newStillRunningError("target", "key")
+	if err == nil || err.Error() != exptectedErr.Error() {
+		t.Fatalf("Expected to have \"%v\" error, but got \"%v\"", exptectedErr, err)
+	}
+
+	clock.Advance(5 * time.Second)
+	result, err := manager.Call(operation)
+	if err != nil || result == nil || result.result != "result" {
+		t.Fatalf("Expected to have \"result\" result, but got \"%v\" and \"%v\"", result, err)
+	}
+}
+
+func TestOperationReferenceClock(t *testing.T) {
+	reference := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(reference)
+	manager := New[mockedPayload, mockedResult, *mockedCtx](newBatchRepository(), WithClock(clock))
+	fn := func(*mockedCtx) (*mockedResult, error) {
+		return newMockedResult("result"), nil
+	}
+
+	operation := NewOperation("key", "target", fn)
+	clock.Advance(time.Second)
+	if _, err := manager.Call(operation); err != nil {
+		t.Fatalf("Expected to have no error, but got \"%v\"", err)
+	}
+
+	clock.Advance(time.Second)
+	if !operation.ReferenceTime().Equal(reference.Add(time.Second)) {
+		t.Fatalf("Expected to have reference time taken from manager clock when called, but got \"%v\"", operation.ReferenceTime())
+	}
+
+	given := NewOperation("given", "target", fn).WithReferenceTime(reference)
+	manager.Call(given)
+	if !given.ReferenceTime().Equal(reference) {
+		t.Fatalf("Expected to have given reference time, but got \"%v\"", given.ReferenceTime())
+	}
+}
@@ -0,0 +1,136 @@
+package ana
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// MarshalableError is implemented by errors that keep their code and details
+// when persisted by repositories, so that they are rebuilt on replay by the
+// decoder registered for their code.
+type MarshalableError interface {
+	error
+	ErrorCode() string
+	ErrorDetails() any
+}
+
+// EncodedError is the structured form errors are persisted with. It is itself
+// returned on replay for codes with no registered decoder.
+type EncodedError struct {
+	Code    string          `json:"code,omitempty"`
+	Message string          `json:"message"`
+	Details json.RawMessage `json:"details,omitempty"`
+}
+
+// EncodeError takes code and details from the first MarshalableError found on
+// err chain, but always keeps err message.
+func EncodeError(err error) (*EncodedError, error) {
+	encoded := &EncodedError{Message: err.Error()}
+
+	var marshalable MarshalableError
+	if !errors.As(err, &marshalable) {
+		return encoded, nil
+	}
+
+	encoded.Code = marshalable.ErrorCode()
+	if details := marshalable.ErrorDetails(); details != nil {
+		raw, err := json.Marshal(details)
+		if err != nil {
+			return nil, err
+		}
+
+		encoded.Details = raw
+	}
+
+	return encoded, nil
+}
+
+func (err *EncodedError) Error() string {
+	return err.Message
+}
+
+func (err *EncodedError) ErrorCode() string {
+	return err.Code
+}
+
+func (err *EncodedError) ErrorDetails() any {
+	if len(err.Details) == 0 {
+		return nil
+	}
+
+	return err.Details
+}
+
+type ErrorDecoder func(*EncodedError) error
+
+// ErrorRegistry rebuilds typed errors from their encoded form by their codes.
+type ErrorRegistry struct {
+	mutex    sync.RWMutex
+	decoders map[string]ErrorDecoder
+}
+
+const timeoutErrorCode string = "ana.timeout"
+
+// DefaultErrorRegistry is used by repositories unless told otherwise.
+var DefaultErrorRegistry *ErrorRegistry = NewErrorRegistry()
+
+// NewErrorRegistry returns a registry already able to rebuild TimeoutError.
+func NewErrorRegistry() *ErrorRegistry {
+	registry := &ErrorRegistry{decoders: map[string]ErrorDecoder{}}
+	registry.Register(timeoutErrorCode, decodeTimeoutError)
+
+	return registry
+}
+
+func decodeTimeoutError(encoded *EncodedError) error {
+	var details map[string]string
+	if err := json.Unmarshal(encoded.Details, &details); err != nil {
+		return encoded
+	}
+
+	return newTimeoutError(details["target"], details["key"], nil)
+}
+
+func (registry *ErrorRegistry) Register(code string, decoder ErrorDecoder) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.decoders[code] = decoder
+}
+
+// RegisterError registers a decoder for code that unmarshals details into a new
+// E, which is usually the error type itself.
+func RegisterError[E any, PE interface {
+	*E
+	error
+}](registry *ErrorRegistry, code string) {
+	registry.Register(code, func(encoded *EncodedError) error {
+		var decoded E
+		if len(encoded.Details) > 0 {
+			if err := json.Unmarshal(encoded.Details, &decoded); err != nil {
+				return encoded
+			}
+		}
+
+		return PE(&decoded)
+	})
+}
+
+// Decode returns the error rebuilt by the decoder of given code, the encoded
+// error itself for unknown codes, or a plain error when it has no code.
+func (registry *ErrorRegistry) Decode(encoded *EncodedError) error {
+	if encoded.Code == "" {
+		return errors.New(encoded.Message)
+	}
+
+	registry.mutex.RLock()
+	decoder, ok := registry.decoders[encoded.Code]
+	registry.mutex.RUnlock()
+
+	if !ok {
+		return encoded
+	}
+
+	return decoder(encoded)
+}
@@ -0,0 +1,54 @@
+package ana
+
+import (
+	"errors"
+	"fmt"
+
+	"testing"
+)
+
+type quotaError struct {
+	Account string `json:"account"`
+	Limit   int    `json:"limit"`
+}
+
+func (err *quotaError) Error() string {
+	return fmt.Sprintf("Account %s exceeded its quota of %d", err.Account, err.Limit)
+}
+
+func (err *quotaError) ErrorCode() string {
+	return "quota_exceeded"
+}
+
+func (err *quotaError) ErrorDetails() any {
+	return err
+}
+
+func TestErrorRegistry(t *testing.T) {
+	registry := NewErrorRegistry()
+	RegisterError[quotaError](registry, "quota_exceeded")
+
+	encoded, err := EncodeError(fmt.Errorf("wrapped: %w", &quotaError{"acme", 10}))
+	if err != nil {
+		t.Fatalf("Expected to have no error, but got \"%v\"", err)
+	}
+
+	if encoded.Code != "quota_exceeded" || encoded.Message != "wrapped: Account acme exceeded its quota of 10" {
+		t.Fatalf("Expected to have code and message, but got \"%s\" and \"%s\"", encoded.Code, encoded.Message)
+	}
+
+	var decoded *quotaError
+	if !errors.As(registry.Decode(encoded), &decoded) || decoded.Account != "acme" || decoded.Limit != 10 {
+		t.Fatalf("Expected to rebuild quota error, but got \"%v\"", decoded)
+	}
+
+	unknown := NewErrorRegistry().Decode(encoded)
+	if coded, ok := unknown.(MarshalableError); !ok || coded.ErrorCode() != "quota_exceeded" || unknown.Error() != encoded.Message {
+		t.Fatalf("Expected to keep unknown code, but got \"%v\"", unknown)
+	}
+
+	plain, _ := EncodeError(errors.New("Something went wrong"))
+	if plain.Code != "" || registry.Decode(plain).Error() != "Something went wrong" {
+		t.Fatalf("Expected to have plain error, but got \"%v\"", plain)
+	}
+}
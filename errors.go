@@ -1,6 +1,26 @@
 package ana
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// Sentinels matching, through errors.Is, every error of their kind regardless
+// of operation they refer to.
+var (
+	ErrExpired      = errors.New("operation expired")
+	ErrTimeout      = errors.New("operation timed out")
+	ErrStillRunning = errors.New("operation still running")
+	ErrNotFound     = errors.New("operation not found")
+	ErrSuperseded   = errors.New("operation superseded")
+	ErrPanic        = errors.New("operation panicked")
+	ErrInvalidKey   = errors.New("invalid idempotency key")
+)
+
+// ErrLookupUnsupported is returned by Manager.Lookup when its repository can
+// not look operations up.
+var ErrLookupUnsupported = errors.New("repository does not support lookups")
 
 type ExpirationError struct {
 	target string
@@ -15,6 +35,58 @@ func (err *ExpirationError) Error() string {
 	return fmt.Sprintf("Operation %v expired for key %v.", err.target, err.key)
 }
 
+func (err *ExpirationError) Target() string {
+	return err.target
+}
+
+func (err *ExpirationError) Key() string {
+	return err.key
+}
+
+func (err *ExpirationError) Is(target error) bool {
+	return target == ErrExpired
+}
+
+// TimeoutError is returned when an operation fails after running past its
+// timeout, wrapping the error it failed with.
+type TimeoutError struct {
+	target string
+	key    string
+	err    error
+}
+
+func newTimeoutError(target string, key string, err error) *TimeoutError {
+	return &TimeoutError{target: target, key: key, err: err}
+}
+
+func (err *TimeoutError) Error() string {
+	return fmt.Sprintf("Operation %v timed out for key %v.", err.target, err.key)
+}
+
+func (err *TimeoutError) Target() string {
+	return err.target
+}
+
+func (err *TimeoutError) Key() string {
+	return err.key
+}
+
+func (err *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+func (err *TimeoutError) Unwrap() error {
+	return err.err
+}
+
+func (err *TimeoutError) ErrorCode() string {
+	return timeoutErrorCode
+}
+
+func (err *TimeoutError) ErrorDetails() any {
+	return map[string]string{"target": err.target, "key": err.key}
+}
+
 type StillRunningError struct {
 	target string
 	key    string
@@ -28,14 +100,144 @@ func (err *StillRunningError) Error() string {
 	return fmt.Sprintf("Operation %v still running for key %v.", err.target, err.key)
 }
 
+func (err *StillRunningError) Target() string {
+	return err.target
+}
+
+func (err *StillRunningError) Key() string {
+	return err.key
+}
+
+func (err *StillRunningError) Is(target error) bool {
+	return target == ErrStillRunning
+}
+
+type NotFoundError struct {
+	target string
+	key    string
+}
+
+func newNotFoundError(target string, key string) *NotFoundError {
+	return &NotFoundError{target: target, key: key}
+}
+
+func (err *NotFoundError) Error() string {
+	return fmt.Sprintf("Operation %v not found for key %v.", err.target, err.key)
+}
+
+func (err *NotFoundError) Target() string {
+	return err.target
+}
+
+func (err *NotFoundError) Key() string {
+	return err.key
+}
+
+func (err *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// SupersededError is returned when an attempt to run an operation finishes
+// after another attempt took it over, so that its outcome is discarded.
+type SupersededError struct {
+	target string
+	key    string
+}
+
+func NewSupersededError(target string, key string) *SupersededError {
+	return &SupersededError{target: target, key: key}
+}
+
+func (err *SupersededError) Error() string {
+	return fmt.Sprintf("Operation %v superseded by another attempt for key %v.", err.target, err.key)
+}
+
+func (err *SupersededError) Target() string {
+	return err.target
+}
+
+func (err *SupersededError) Key() string {
+	return err.key
+}
+
+func (err *SupersededError) Is(target error) bool {
+	return target == ErrSuperseded
+}
+
+// PanicError is returned when an operation panics, keeping the recovered value
+// along with the stack trace where it was raised.
 type PanicError struct {
-	err interface{}
+	err   interface{}
+	stack []byte
 }
 
 func newPanicError(err interface{}) *PanicError {
-	return &PanicError{err: err}
+	return &PanicError{err: err, stack: debug.Stack()}
 }
 
 func (err *PanicError) Error() string {
 	return fmt.Sprintf("Got panic \"%v\"", err.err)
 }
+
+func (err *PanicError) Value() interface{} {
+	return err.err
+}
+
+func (err *PanicError) Stack() []byte {
+	return err.stack
+}
+
+func (err *PanicError) Is(target error) bool {
+	return target == ErrPanic
+}
+
+// Unwrap returns recovered value whenever it is an error.
+func (err *PanicError) Unwrap() error {
+	if wrapped, ok := err.err.(error); ok {
+		return wrapped
+	}
+
+	return nil
+}
+
+// InvalidKeyError is returned when an idempotency key is rejected by manager
+// KeyPolicy, before any repository is reached.
+type InvalidKeyError struct {
+	key    string
+	reason string
+}
+
+func newInvalidKeyError(key string, reason string) *InvalidKeyError {
+	return &InvalidKeyError{key: key, reason: reason}
+}
+
+func (err *InvalidKeyError) Error() string {
+	return fmt.Sprintf("Invalid idempotency key %q: %v.", err.key, err.reason)
+}
+
+func (err *InvalidKeyError) Key() string {
+	return err.key
+}
+
+func (err *InvalidKeyError) Reason() string {
+	return err.reason
+}
+
+func (err *InvalidKeyError) Is(target error) bool {
+	return target == ErrInvalidKey
+}
+
+// failError is returned when a failed operation could not be recorded, so that
+// both recording error and operation one stay reachable by errors.Is and As.
+type failError struct {
+	err   error
+	cause error
+}
+
+func (err *failError) Error() string {
+	return err.err.Error()
+}
+
+func (err *failError) Unwrap() []error {
+	return []error{err.err, err.cause}
+}
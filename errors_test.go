@@ -0,0 +1,149 @@
+package ana
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+func TestErrorSentinels(t *testing.T) {
+	cases := []struct {
+		err      error
+		sentinel error
+	}{
+		{newExpirationError("target", "key"), ErrExpired},
+		{newStillRunningError("target", "key"), ErrStillRunning},
+		{newNotFoundError("target", "key"), ErrNotFound},
+		{NewSupersededError("target", "key"), ErrSuperseded},
+		{newPanicError("Boom!"), ErrPanic},
+		{newInvalidKeyError("", "key is required"), ErrInvalidKey},
+	}
+
+	for _, c := range cases {
+		wrapped := fmt.Errorf("wrapped: %w", c.err)
+		if !errors.Is(wrapped, c.sentinel) {
+			t.Fatalf("Expected \"%v\" to be \"%v\"", c.err, c.sentinel)
+		}
+
+		if errors.Is(wrapped, ErrNotFound) != (c.sentinel == ErrNotFound) {
+			t.Fatalf("Expected \"%v\" to not be \"%v\"", c.err, ErrNotFound)
+		}
+	}
+
+	var expirationErr *ExpirationError
+	if !errors.As(fmt.Errorf("wrapped: %w", newExpirationError("target", "key")), &expirationErr) {
+		t.Fatalf("Expected to have expiration error")
+	}
+
+	if expirationErr.Target() != "target" || expirationErr.Key() != "key" {
+		t.Fatalf("Expected to have \"target\" and \"key\", but got \"%s\" and \"%s\"", expirationErr.Target(), expirationErr.Key())
+	}
+}
+
+func TestPanicErrorUnwrap(t *testing.T) {
+	cause := errors.New("cause")
+	err := newPanicError(cause)
+
+	if !errors.Is(err, cause) || err.Value() != cause {
+		t.Fatalf("Expected \"%v\" to wrap \"%v\"", err, cause)
+	}
+
+	if !strings.Contains(string(err.Stack()), "TestPanicErrorUnwrap") {
+		t.Fatalf("Expected to have stack trace, but got \"%s\"", err.Stack())
+	}
+
+	if newPanicError("Boom!").Unwrap() != nil {
+		t.Fatalf("Expected to not wrap non error values")
+	}
+}
+
+func TestFailedRecordingKeepsOperationError(t *testing.T) {
+	operation := newMockedOperation(
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		time.Now(),
+		5*time.Second,
+		10*time.Second,
+		newMockedPanicFn("Boom!"),
+	)
+
+	ctx := newMockedCtx()
+	ctx.Err = NewSupersededError("target", "key")
+	session := NewSession(operation, ctx)
+	session.call()
+	session.close()
+
+	if !errors.Is(session.err, ErrSuperseded) || !errors.Is(session.err, ErrPanic) {
+		t.Fatalf("Expected to have both superseded and panic errors, but got \"%v\"", session.err)
+	}
+
+	if session.err.Error() != ctx.Err.Error() {
+		t.Fatalf("Expected to have \"%v\" error, but got \"%v\"", ctx.Err, session.err)
+	}
+}
+
+func TestRepanic(t *testing.T) {
+	manager := New[mockedPayload, mockedResult, *mockedCtx](newEmptyRepository(), WithRepanic())
+	operation := newMockedOperation(
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		time.Now(),
+		5*time.Second,
+		10*time.Second,
+		newMockedPanicFn("Boom!"),
+	)
+
+	defer func() {
+		if recovery := recover(); recovery != "Boom!" {
+			t.Fatalf("Expected to panic with \"Boom!\", but got \"%v\"", recovery)
+		}
+	}()
+
+	manager.Call(operation)
+	t.Fatalf("Expected to panic")
+}
+
+func TestTimedOutOperation(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	manager := New[mockedPayload, mockedResult, *mockedCtx](newEmptyRepository(), WithClock(clock))
+
+	cause := errors.New("context deadline exceeded")
+	operation := newMockedOperation(
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		clock.Now(),
+		5*time.Second,
+		10*time.Second,
+		func() (*mockedResult, error) {
+			clock.Advance(5 * time.Second)
+			return nil, cause
+		},
+	)
+
+	_, err := manager.Call(operation)
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || !errors.Is(err, ErrTimeout) || !errors.Is(err, cause) {
+		t.Fatalf("Expected to have timeout error wrapping \"%v\", but got \"%v\"", cause, err)
+	}
+
+	encoded, _ := EncodeError(err)
+	if decoded := DefaultErrorRegistry.Decode(encoded); !errors.As(decoded, &timeoutErr) || timeoutErr.Key() != "key" {
+		t.Fatalf("Expected to rebuild timeout error, but got \"%v\"", decoded)
+	}
+
+	operation.result = func() (*mockedResult, error) {
+		clock.Advance(5 * time.Second)
+		return newMockedResult("result"), nil
+	}
+
+	if result, err := manager.Call(operation); result != nil || !errors.As(err, &timeoutErr) || errors.Unwrap(err) != nil {
+		t.Fatalf("Expected to have late success timed out, but got \"%v\" and \"%v\"", result, err)
+	}
+}
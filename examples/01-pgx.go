@@ -40,6 +40,10 @@ func newPool() *pgxpool.Pool {
 		panic(err)
 	}
 
+	if err := r.Migrate(context.Background(), pool); err != nil {
+		panic(err)
+	}
+
 	return pool
 }
 
@@ -0,0 +1,110 @@
+package ana
+
+import (
+	"sync"
+	"time"
+)
+
+// FuncOperation is an Operation calling a function, built with NewOperation.
+// Unless told otherwise, it has no payload, its reference time is when it is
+// first called, by the clock of the manager calling it, and it has neither
+// timeout nor expiration.
+type FuncOperation[P any, R any, C SessionCtx[P, R]] struct {
+	mutex         sync.Mutex
+	key           string
+	target        string
+	payload       *P
+	referenceTime time.Time
+	timeout       time.Duration
+	expiration    time.Duration
+	fn            func(C) (*R, error)
+}
+
+func NewOperation[P any, R any, C SessionCtx[P, R]](key string, target string, fn func(C) (*R, error)) *FuncOperation[P, R, C] {
+	return &FuncOperation[P, R, C]{
+		key:    key,
+		target: target,
+		fn:     fn,
+	}
+}
+
+func (operation *FuncOperation[P, R, C]) WithTarget(target string) *FuncOperation[P, R, C] {
+	operation.target = target
+	return operation
+}
+
+func (operation *FuncOperation[P, R, C]) WithPayload(payload *P) *FuncOperation[P, R, C] {
+	operation.payload = payload
+	return operation
+}
+
+func (operation *FuncOperation[P, R, C]) WithReferenceTime(referenceTime time.Time) *FuncOperation[P, R, C] {
+	operation.mutex.Lock()
+	defer operation.mutex.Unlock()
+
+	operation.referenceTime = referenceTime
+	return operation
+}
+
+func (operation *FuncOperation[P, R, C]) WithTimeout(timeout time.Duration) *FuncOperation[P, R, C] {
+	operation.timeout = timeout
+	return operation
+}
+
+func (operation *FuncOperation[P, R, C]) WithExpiration(expiration time.Duration) *FuncOperation[P, R, C] {
+	operation.expiration = expiration
+	return operation
+}
+
+func (operation *FuncOperation[P, R, C]) Key() string {
+	return operation.key
+}
+
+func (operation *FuncOperation[P, R, C]) Target() string {
+	return operation.target
+}
+
+func (operation *FuncOperation[P, R, C]) Payload() *P {
+	return operation.payload
+}
+
+// ReferenceTime is taken from SystemClock when operation is used before being
+// called by a manager, and never changes afterwards.
+func (operation *FuncOperation[P, R, C]) ReferenceTime() time.Time {
+	return operation.referenceFrom(SystemClock)
+}
+
+func (operation *FuncOperation[P, R, C]) referenceFrom(clock Clock) time.Time {
+	operation.mutex.Lock()
+	defer operation.mutex.Unlock()
+
+	if operation.referenceTime.IsZero() {
+		operation.referenceTime = clock.Now()
+	}
+
+	return operation.referenceTime
+}
+
+func (operation *FuncOperation[P, R, C]) Timeout() time.Duration {
+	return operation.timeout
+}
+
+func (operation *FuncOperation[P, R, C]) Expiration() time.Duration {
+	return operation.expiration
+}
+
+func (operation *FuncOperation[P, R, C]) Call(ctx C) (*R, error) {
+	return operation.fn(ctx)
+}
+
+// Do calls fn through manager as an operation with given key, target and
+// payload, for ad hoc idempotent calls.
+func Do[P any, R any, C SessionCtx[P, R]](
+	manager *Manager[P, R, C],
+	key string,
+	target string,
+	payload *P,
+	fn func(C) (*R, error),
+) (*R, error) {
+	return manager.Call(NewOperation[P, R, C](key, target, fn).WithPayload(payload))
+}
@@ -0,0 +1,64 @@
+package ana
+
+import (
+	"time"
+
+	"testing"
+)
+
+func TestNewOperation(t *testing.T) {
+	referenceTime := time.Now().Add(-time.Minute)
+	payload := newMockedPayload("payload")
+
+	operation := NewOperation("key", "target", func(*mockedCtx) (*mockedResult, error) {
+		return newMockedResult("result"), nil
+	}).
+		WithPayload(payload).
+		WithReferenceTime(referenceTime).
+		WithTimeout(5 * time.Second).
+		WithExpiration(10 * time.Second)
+
+	if operation.Key() != "key" || operation.Target() != "target" || operation.Payload() != payload {
+		t.Fatalf("Expected to have given key, target and payload, but got \"%s\", \"%s\" and \"%v\"", operation.Key(), operation.Target(), operation.Payload())
+	}
+
+	if !operation.ReferenceTime().Equal(referenceTime) || operation.Timeout() != 5*time.Second || operation.Expiration() != 10*time.Second {
+		t.Fatalf("Expected to have given times, but got \"%v\", \"%v\" and \"%v\"", operation.ReferenceTime(), operation.Timeout(), operation.Expiration())
+	}
+
+	if result, err := operation.Call(newMockedCtx()); err != nil || result.result != "result" {
+		t.Fatalf("Expected to have \"result\" result, but got \"%v\" and \"%v\"", result, err)
+	}
+}
+
+func TestDo(t *testing.T) {
+	trackedOperation := NewTrackedOperation(
+		Finished,
+		"finished",
+		"target",
+		newMockedPayload("payload"),
+		time.Now().Add(-10*time.Second),
+		time.Now().Add(-5*time.Second),
+		time.Now().Add(5*time.Second),
+		time.Now().Add(10*time.Second),
+		newMockedResult("tracked result"),
+		nil,
+	)
+	manager := New[mockedPayload, mockedResult, *mockedCtx](newBatchRepository(trackedOperation))
+
+	calls := 0
+	fn := func(*mockedCtx) (*mockedResult, error) {
+		calls += 1
+		return newMockedResult("result"), nil
+	}
+
+	result, err := Do(manager, "finished", "target", newMockedPayload("payload"), fn)
+	if err != nil || result.result != "tracked result" || calls != 0 {
+		t.Fatalf("Expected to have \"tracked result\" result, but got \"%v\" and \"%v\"", result, err)
+	}
+
+	result, err = Do(manager, "new", "target", newMockedPayload("payload"), fn)
+	if err != nil || result.result != "result" || calls != 1 {
+		t.Fatalf("Expected to have \"result\" result, but got \"%v\" and \"%v\"", result, err)
+	}
+}
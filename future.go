@@ -0,0 +1,53 @@
+package ana
+
+import "context"
+
+type Future[R any] struct {
+	done   chan struct{}
+	result *R
+	err    error
+}
+
+func newFuture[R any]() *Future[R] {
+	return &Future[R]{done: make(chan struct{})}
+}
+
+func newResolvedFuture[R any](result *R, err error) *Future[R] {
+	future := newFuture[R]()
+	future.resolve(result, err)
+
+	return future
+}
+
+func (future *Future[R]) resolve(result *R, err error) {
+	future.result = result
+	future.err = err
+	close(future.done)
+}
+
+func (future *Future[R]) Done() <-chan struct{} {
+	return future.done
+}
+
+func (future *Future[R]) Ready() bool {
+	select {
+	case <-future.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (future *Future[R]) Wait() (*R, error) {
+	<-future.done
+	return future.result, future.err
+}
+
+func (future *Future[R]) WaitContext(ctx context.Context) (*R, error) {
+	select {
+	case <-future.done:
+		return future.result, future.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
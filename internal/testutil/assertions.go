@@ -0,0 +1,48 @@
+// Package testutil holds assertions and mocks shared by repository tests.
+package testutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func AssertEqual(t *testing.T, expected, value any) {
+	t.Helper()
+
+	if expected != value {
+		t.Fatalf("Expected \"%v\" to be equal to \"%v\", but wasn't.", expected, value)
+	}
+}
+
+func AssertTimeEqual(t *testing.T, expected, value time.Time) {
+	t.Helper()
+
+	if !expected.Equal(value) {
+		t.Fatalf("Expected \"%v\" to be equal to \"%v\", but wasn't.", expected, value)
+	}
+}
+
+func AssertNil[R any](t *testing.T, expected *R) {
+	t.Helper()
+
+	if expected != nil {
+		t.Fatalf("Expected \"%v\" to be nil, but wasn't.", expected)
+	}
+}
+
+func AssertErrorNil(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("Expected \"%v\" to be nil, but wasn't.", err)
+	}
+}
+
+func AssertContains(t *testing.T, value, expected string) {
+	t.Helper()
+
+	if !strings.Contains(value, expected) {
+		t.Fatalf("Expected \"%v\" to contain \"%v\", but didn't.", value, expected)
+	}
+}
@@ -0,0 +1,86 @@
+package testutil
+
+import (
+	"errors"
+	"time"
+)
+
+type DebugPayload struct {
+	Value string
+}
+
+type DebugResult struct {
+	Value string
+}
+
+// MockedOperation succeeds with its result, or fails with it as error message,
+// on whatever context C repositories give it.
+type MockedOperation[C any] struct {
+	key           string
+	target        string
+	payload       *DebugPayload
+	referenceTime time.Time
+	timeout       time.Duration
+	expiration    time.Duration
+	result        string
+	success       bool
+}
+
+func NewMockedOperation[C any](key, target, payload, result string, success bool) *MockedOperation[C] {
+	now := time.Now()
+
+	return &MockedOperation[C]{
+		key:        key,
+		target:     target,
+		payload:    &DebugPayload{payload},
+		result:     result,
+		success:    success,
+		timeout:    1 * time.Minute,
+		expiration: 1 * time.Minute,
+		referenceTime: time.Date(
+			now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), 0, 0, time.UTC,
+		),
+	}
+}
+
+func (o *MockedOperation[C]) WithTimeout(timeout time.Duration) *MockedOperation[C] {
+	o.timeout = timeout
+	return o
+}
+
+func (o *MockedOperation[C]) WithExpiration(expiration time.Duration) *MockedOperation[C] {
+	o.expiration = expiration
+	return o
+}
+
+func (o *MockedOperation[C]) Key() string {
+	return o.key
+}
+
+func (o *MockedOperation[C]) Target() string {
+	return o.target
+}
+
+func (o *MockedOperation[C]) Payload() *DebugPayload {
+	return o.payload
+}
+
+func (o *MockedOperation[C]) ReferenceTime() time.Time {
+	return o.referenceTime
+}
+
+func (o *MockedOperation[C]) Timeout() time.Duration {
+	return o.timeout
+}
+
+func (o *MockedOperation[C]) Expiration() time.Duration {
+	return o.expiration
+}
+
+func (o *MockedOperation[C]) Call(ctx C) (*DebugResult, error) {
+	if o.success {
+		return &DebugResult{o.result}, nil
+	}
+
+	return nil, errors.New(o.result)
+}
@@ -0,0 +1,106 @@
+package ana
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+type KeyFormat int
+
+const (
+	AnyKeyFormat KeyFormat = iota
+	UUIDKeyFormat
+	ULIDKeyFormat
+)
+
+type KeyCase int
+
+const (
+	PreserveKeyCase KeyCase = iota
+	LowerKeyCase
+	UpperKeyCase
+)
+
+// DefaultKeyMaxLength is how many characters keys may have by default, which is
+// as long as every repository is able to store.
+const DefaultKeyMaxLength int = 255
+
+var (
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	ulidPattern = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Za-hjkmnp-tv-z]{25}$`)
+)
+
+// KeyPolicy tells which idempotency keys are accepted. Its zero value only
+// requires keys to be non empty, while Manager by default also bounds them to
+// DefaultKeyMaxLength.
+type KeyPolicy struct {
+	// Optional accepts empty keys, which are all tracked as the same operation.
+	Optional bool
+
+	// MaxLength bounds how many characters a key may have, when positive.
+	MaxLength int
+
+	// Charset lists every character a key may have, when not empty.
+	Charset string
+
+	Format KeyFormat
+	Case   KeyCase
+}
+
+// Normalize applies policy case to given key and validates it, returning an
+// InvalidKeyError when it is rejected.
+func (policy KeyPolicy) Normalize(key string) (string, error) {
+	switch policy.Case {
+	case LowerKeyCase:
+		key = strings.ToLower(key)
+	case UpperKeyCase:
+		key = strings.ToUpper(key)
+	}
+
+	if key == "" {
+		if policy.Optional {
+			return key, nil
+		}
+
+		return key, newInvalidKeyError(key, "key is required")
+	}
+
+	if policy.MaxLength > 0 && utf8.RuneCountInString(key) > policy.MaxLength {
+		return key, newInvalidKeyError(key, "key is too long")
+	}
+
+	if policy.Charset != "" {
+		for _, char := range key {
+			if !strings.ContainsRune(policy.Charset, char) {
+				return key, newInvalidKeyError(key, "key has forbidden characters")
+			}
+		}
+	}
+
+	switch policy.Format {
+	case UUIDKeyFormat:
+		if !uuidPattern.MatchString(key) {
+			return key, newInvalidKeyError(key, "key is not an UUID")
+		}
+	case ULIDKeyFormat:
+		if !ulidPattern.MatchString(key) {
+			return key, newInvalidKeyError(key, "key is not an ULID")
+		}
+	}
+
+	return key, nil
+}
+
+type keyedOperation[P any, R any, C SessionCtx[P, R]] struct {
+	Operation[P, R, C]
+	key string
+}
+
+func (operation *keyedOperation[P, R, C]) Key() string {
+	return operation.key
+}
+
+func (operation *keyedOperation[P, R, C]) unwrapOperation() any {
+	return operation.Operation
+}
@@ -0,0 +1,114 @@
+package ana
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+func TestKeyPolicy(t *testing.T) {
+	cases := []struct {
+		policy   KeyPolicy
+		key      string
+		expected string
+		valid    bool
+	}{
+		{KeyPolicy{}, "key", "key", true},
+		{KeyPolicy{}, "", "", false},
+		{KeyPolicy{Optional: true}, "", "", true},
+		{KeyPolicy{MaxLength: 3}, "key", "key", true},
+		{KeyPolicy{MaxLength: 3}, "keys", "keys", false},
+		{KeyPolicy{Charset: "abcdefghijklmnopqrstuvwxyz-"}, "some-key", "some-key", true},
+		{KeyPolicy{Charset: "abcdefghijklmnopqrstuvwxyz-"}, "some_key", "some_key", false},
+		{KeyPolicy{Format: UUIDKeyFormat}, "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", true},
+		{KeyPolicy{Format: UUIDKeyFormat}, "6ba7b810-9dad-11d1-80b4", "6ba7b810-9dad-11d1-80b4", false},
+		{KeyPolicy{Format: ULIDKeyFormat}, "01ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAV", true},
+		{KeyPolicy{Format: ULIDKeyFormat}, "01ARZ3NDEKTSV4RRFFQ69G5FAU", "01ARZ3NDEKTSV4RRFFQ69G5FAU", false},
+		{KeyPolicy{Format: ULIDKeyFormat}, "81ARZ3NDEKTSV4RRFFQ69G5FAV", "81ARZ3NDEKTSV4RRFFQ69G5FAV", false},
+		{KeyPolicy{Case: LowerKeyCase}, "Some-Key", "some-key", true},
+		{KeyPolicy{Case: UpperKeyCase, Format: ULIDKeyFormat}, "01arz3ndektsv4rrffq69g5fav", "01ARZ3NDEKTSV4RRFFQ69G5FAV", true},
+	}
+
+	for _, c := range cases {
+		key, err := c.policy.Normalize(c.key)
+		if key != c.expected {
+			t.Fatalf("Expected %q to be normalized to %q, but got %q", c.key, c.expected, key)
+		}
+
+		var invalidKeyErr *InvalidKeyError
+		if c.valid && err != nil {
+			t.Fatalf("Expected %q to be valid, but got \"%v\"", c.key, err)
+		}
+
+		if !c.valid && !errors.As(err, &invalidKeyErr) {
+			t.Fatalf("Expected %q to be invalid, but got \"%v\"", c.key, err)
+		}
+	}
+}
+
+func TestInvalidKeyDoesNotReachRepository(t *testing.T) {
+	repository := newBatchRepository()
+	manager := New[mockedPayload, mockedResult, *mockedCtx](repository)
+
+	operation := newMockedOperation("", "target", newMockedPayload("payload"), time.Now(), 5*time.Second, 10*time.Second, newMockedResultFn("result"))
+
+	var invalidKeyErr *InvalidKeyError
+	if _, err := manager.Call(operation); !errors.As(err, &invalidKeyErr) {
+		t.Fatalf("Expected to have invalid key error, but got \"%v\"", err)
+	}
+
+	if _, err := manager.CallAsync(operation).Wait(); !errors.As(err, &invalidKeyErr) {
+		t.Fatalf("Expected to have invalid key error, but got \"%v\"", err)
+	}
+
+	_, errs := manager.CallBatch([]Operation[mockedPayload, mockedResult, *mockedCtx]{operation})
+	if !errors.As(errs[0], &invalidKeyErr) {
+		t.Fatalf("Expected to have invalid key error, but got \"%v\"", errs[0])
+	}
+
+	if repository.fetchCount != 0 || repository.batchCount != 0 {
+		t.Fatalf("Expected repository to be untouched, but got %d fetches and %d batches", repository.fetchCount, repository.batchCount)
+	}
+}
+
+func TestDefaultKeyMaxLength(t *testing.T) {
+	manager := New[mockedPayload, mockedResult, *mockedCtx](newBatchRepository())
+	key := strings.Repeat("k", DefaultKeyMaxLength)
+
+	operation := newMockedOperation(key, "target", newMockedPayload("payload"), time.Now(), 5*time.Second, 10*time.Second, newMockedResultFn("result"))
+	if _, err := manager.Call(operation); err != nil {
+		t.Fatalf("Expected %d characters long key to be valid, but got \"%v\"", DefaultKeyMaxLength, err)
+	}
+
+	operation = newMockedOperation(key+"k", "target", newMockedPayload("payload"), time.Now(), 5*time.Second, 10*time.Second, newMockedResultFn("result"))
+	if _, err := manager.Call(operation); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("Expected to have invalid key error, but got \"%v\"", err)
+	}
+}
+
+func TestNormalizedKey(t *testing.T) {
+	trackedOperation := NewTrackedOperation(
+		Finished,
+		"some-key",
+		"target",
+		newMockedPayload("payload"),
+		time.Now().Add(-10*time.Second),
+		time.Now().Add(-5*time.Second),
+		time.Now().Add(5*time.Second),
+		time.Now().Add(10*time.Second),
+		newMockedResult("tracked result"),
+		nil,
+	)
+	manager := New[mockedPayload, mockedResult, *mockedCtx](
+		newBatchRepository(trackedOperation),
+		WithKeyPolicy(KeyPolicy{Case: LowerKeyCase}),
+	)
+
+	operation := newMockedOperation("Some-Key", "target", newMockedPayload("payload"), time.Now(), 5*time.Second, 10*time.Second, newMockedResultFn("result"))
+	result, err := manager.Call(operation)
+	if err != nil || result == nil || result.result != "tracked result" {
+		t.Fatalf("Expected to have \"tracked result\" result, but got \"%v\" and \"%v\"", result, err)
+	}
+}
@@ -4,51 +4,273 @@ import "time"
 
 type Manager[P any, R any, C SessionCtx[P, R]] struct {
 	repository IdempotencyRepository[P, R, C]
+	workers    chan struct{}
+	clock      Clock
+	keyPolicy  KeyPolicy
+	repanic    bool
 }
 
-func New[P any, R any, C SessionCtx[P, R]](repository IdempotencyRepository[P, R, C]) *Manager[P, R, C] {
-	return &Manager[P, R, C]{repository: repository}
+func New[P any, R any, C SessionCtx[P, R]](repository IdempotencyRepository[P, R, C], opts ...Option) *Manager[P, R, C] {
+	options := newOptions(opts)
+
+	clock := options.clock
+	if clock == nil {
+		clock = SystemClock
+		if provider, ok := repository.(ClockProvider); ok {
+			clock = provider.Clock()
+		}
+	}
+
+	return &Manager[P, R, C]{
+		repository: repository,
+		workers:    make(chan struct{}, options.workers),
+		clock:      clock,
+		keyPolicy:  options.keyPolicy,
+		repanic:    options.repanic,
+	}
 }
 
-func (manager *Manager[P, R, C]) Call(operation Operation[P, R, C]) (*R, error) {
+func (manager *Manager[P, R, C]) Call(operation Operation[P, R, C]) (result *R, err error) {
+	defer manager.recoverRepository(&result, &err)
+
+	operation, err = manager.prepare(operation)
+	if err != nil {
+		return nil, err
+	}
+
 	if manager.isExpiredOperation(operation) {
 		return nil, newExpirationError(operation.Target(), operation.Key())
 	}
 
-	trackedOperation := manager.repository.FetchOrStart(operation)
+	return manager.resolve(operation, manager.repository.FetchOrStart(operation))
+}
+
+// CallAsync claims given operation right away, but runs it in background
+// bounded by manager workers. Its outcome is available through returned future.
+func (manager *Manager[P, R, C]) CallAsync(operation Operation[P, R, C]) *Future[R] {
+	operation, err := manager.prepare(operation)
+	if err != nil {
+		return newResolvedFuture[R](nil, err)
+	}
+
+	if manager.isExpiredOperation(operation) {
+		return newResolvedFuture[R](nil, newExpirationError(operation.Target(), operation.Key()))
+	}
+
+	trackedOperation, err := manager.fetchOrStart(operation)
+	if err != nil {
+		return newResolvedFuture[R](nil, err)
+	}
+
+	if result, err, settled := manager.settle(trackedOperation); settled {
+		return newResolvedFuture(result, err)
+	}
+
+	future := newFuture[R]()
+	go func() {
+		manager.workers <- struct{}{}
+		defer func() { <-manager.workers }()
+
+		var result *R
+		var err error
+		defer func() { future.resolve(result, err) }()
+		defer manager.recoverRepository(&result, &err)
+
+		result, err = manager.callOperation(operation)
+	}()
+
+	return future
+}
+
+// Repositories panic on infrastructure failures, which are returned as
+// *PanicError instead, unless manager repanics.
+func (manager *Manager[P, R, C]) recoverRepository(result **R, err *error) {
+	recovery := recover()
+	if recovery == nil {
+		return
+	}
+
+	if manager.repanic {
+		panic(recovery)
+	}
+
+	*result = nil
+	*err = newPanicError(recovery)
+}
+
+func (manager *Manager[P, R, C]) fetchOrStart(operation Operation[P, R, C]) (trackedOperation *TrackedOperation[P, R], err error) {
+	var result *R
+	defer manager.recoverRepository(&result, &err)
+
+	return manager.repository.FetchOrStart(operation), nil
+}
+
+// Lookup retrieves the outcome of an operation previously called by any
+// process, as long as the repository implements LookupRepository. Otherwise it
+// returns ErrLookupUnsupported.
+func (manager *Manager[P, R, C]) Lookup(key string, target string) (result *R, err error) {
+	defer manager.recoverRepository(&result, &err)
+
+	repository, ok := manager.repository.(LookupRepository[P, R])
+	if supporter, wrapper := manager.repository.(LookupSupporter); !ok || (wrapper && !supporter.SupportsLookup()) {
+		return nil, ErrLookupUnsupported
+	}
+
+	key, err = manager.keyPolicy.Normalize(key)
+	if err != nil {
+		return nil, err
+	}
+
+	trackedOperation := repository.Lookup(key, target)
+	if trackedOperation == nil {
+		return nil, newNotFoundError(target, key)
+	}
+
+	if trackedOperation.isFinished() {
+		return trackedOperation.Result, nil
+	}
+
+	if trackedOperation.isExpired(manager.clock.Now()) {
+		return nil, newExpirationError(trackedOperation.Target, trackedOperation.Key)
+	}
+
+	if trackedOperation.isFailed() {
+		return nil, trackedOperation.Err
+	}
+
+	return nil, newStillRunningError(trackedOperation.Target, trackedOperation.Key)
+}
+
+func (manager *Manager[P, R, C]) CallBatch(operations []Operation[P, R, C]) ([]*R, []error) {
+	results := make([]*R, len(operations))
+	errs := make([]error, len(operations))
 
-	if trackedOperation != nil {
-		if trackedOperation.isFinished() {
-			return trackedOperation.Result, nil
+	indexes := make([]int, 0, len(operations))
+	pending := make([]Operation[P, R, C], 0, len(operations))
+	for i, operation := range operations {
+		operation, err := manager.prepare(operation)
+		if err != nil {
+			errs[i] = err
+			continue
 		}
 
-		if trackedOperation.isExpired() {
-			return nil, newExpirationError(trackedOperation.Target, trackedOperation.Key)
+		if manager.isExpiredOperation(operation) {
+			errs[i] = newExpirationError(operation.Target(), operation.Key())
+			continue
 		}
 
-		if trackedOperation.stillRunning() {
-			return nil, newStillRunningError(trackedOperation.Target, trackedOperation.Key)
+		indexes = append(indexes, i)
+		pending = append(pending, operation)
+	}
+
+	if len(pending) == 0 {
+		return results, errs
+	}
+
+	trackedOperations, err := manager.fetchOrStartBatch(pending)
+	for j, i := range indexes {
+		if err != nil {
+			errs[i] = err
+			continue
 		}
+
+		results[i], errs[i] = manager.resolve(pending[j], trackedOperations[j])
+	}
+
+	return results, errs
+}
+
+func (manager *Manager[P, R, C]) fetchOrStartBatch(operations []Operation[P, R, C]) (trackedOperations []*TrackedOperation[P, R], err error) {
+	var result *R
+	defer manager.recoverRepository(&result, &err)
+
+	if repository, ok := manager.repository.(BatchIdempotencyRepository[P, R, C]); ok {
+		return repository.FetchOrStartBatch(operations), nil
+	}
+
+	trackedOperations = make([]*TrackedOperation[P, R], len(operations))
+	for i, operation := range operations {
+		trackedOperations[i] = manager.repository.FetchOrStart(operation)
+	}
+
+	return trackedOperations, nil
+}
+
+func (manager *Manager[P, R, C]) resolve(operation Operation[P, R, C], trackedOperation *TrackedOperation[P, R]) (result *R, err error) {
+	defer manager.recoverRepository(&result, &err)
+
+	if result, err, settled := manager.settle(trackedOperation); settled {
+		return result, err
 	}
 
 	return manager.callOperation(operation)
 }
 
+func (manager *Manager[P, R, C]) settle(trackedOperation *TrackedOperation[P, R]) (result *R, err error, settled bool) {
+	if trackedOperation == nil {
+		return nil, nil, false
+	}
+
+	if trackedOperation.isFinished() {
+		return trackedOperation.Result, nil, true
+	}
+
+	if trackedOperation.isExpired(manager.clock.Now()) {
+		return nil, newExpirationError(trackedOperation.Target, trackedOperation.Key), true
+	}
+
+	if trackedOperation.stillRunning(manager.clock.Now()) {
+		return nil, newStillRunningError(trackedOperation.Target, trackedOperation.Key), true
+	}
+
+	return nil, nil, false
+}
+
 func (manager *Manager[P, R, C]) callOperation(operation Operation[P, R, C]) (*R, error) {
-	session := manager.repository.NewSession(operation)
-	defer session.close()
+	session := manager.newSession(operation)
+	for retries := 0; session.run(retries); retries++ {
+		session = manager.newSession(operation)
+	}
 
-	session.call()
+	if manager.repanic && session.panicked != nil {
+		panic(session.panicked.Value())
+	}
 
 	return session.result, session.err
 }
 
+// prepare pins operation reference time to manager clock, unless it already has
+// one, and normalizes its key.
+func (manager *Manager[P, R, C]) prepare(operation Operation[P, R, C]) (Operation[P, R, C], error) {
+	if referenced, ok := operation.(referencedOperation); ok {
+		referenced.referenceFrom(manager.clock)
+	}
+
+	key, err := manager.keyPolicy.Normalize(operation.Key())
+	if err != nil {
+		return nil, err
+	}
+
+	if key == operation.Key() {
+		return operation, nil
+	}
+
+	return &keyedOperation[P, R, C]{operation, key}, nil
+}
+
+func (manager *Manager[P, R, C]) newSession(operation Operation[P, R, C]) *Session[P, R, C] {
+	session := manager.repository.NewSession(operation)
+	session.clock = manager.clock
+
+	return session
+}
+
 func (manager *Manager[P, R, C]) isExpiredOperation(operation Operation[P, R, C]) bool {
 	if operation.Expiration() == time.Duration(0) {
 		return false
 	}
 
-	return time.Now().After(
+	return manager.clock.Now().After(
 		operation.ReferenceTime().Add(operation.Expiration()),
 	)
 }
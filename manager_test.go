@@ -1,6 +1,8 @@
 package ana
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"testing"
@@ -610,3 +612,309 @@ func TestPanicOperation(t *testing.T) {
 		t.Fatalf("Expected to have no result, but got \"%s\"", result.result)
 	}
 }
+
+func TestCallBatch(t *testing.T) {
+	finishedOperation := NewTrackedOperation(
+		Finished,
+		"finished",
+		"target",
+		newMockedPayload("payload"),
+		time.Now().Add(-10*time.Second),
+		time.Now().Add(-5*time.Second),
+		time.Now().Add(5*time.Second),
+		time.Now().Add(10*time.Second),
+		newMockedResult("tracked result"),
+		nil,
+	)
+	runningOperation := NewTrackedOperation[mockedPayload, mockedResult](
+		Running,
+		"running",
+		"target",
+		newMockedPayload("payload"),
+		time.Now().Add(-10*time.Second),
+		time.Now().Add(-5*time.Second),
+		time.Now().Add(5*time.Second),
+		time.Now().Add(10*time.Second),
+		nil,
+		nil,
+	)
+	repo := newBatchRepository(finishedOperation, runningOperation)
+	manager := New[mockedPayload, mockedResult, *mockedCtx](repo)
+
+	operations := []Operation[mockedPayload, mockedResult, *mockedCtx]{
+		newMockedOperation("finished", "target", newMockedPayload("payload"), time.Now(), 5*time.Second, 10*time.Second, newMockedResultFn("result")),
+		newMockedOperation("expired", "target", newMockedPayload("payload"), time.Now().Add(-20*time.Second), 5*time.Second, 10*time.Second, newMockedResultFn("result")),
+		newMockedOperation("running", "target", newMockedPayload("payload"), time.Now(), 5*time.Second, 10*time.Second, newMockedResultFn("result")),
+		newMockedOperation("new", "target", newMockedPayload("payload"), time.Now(), 5*time.Second, 10*time.Second, newMockedResultFn("result")),
+		newMockedOperation("failing", "target", newMockedPayload("payload"), time.Now(), 5*time.Second, 10*time.Second, newMockedErrorFn("Boom!")),
+	}
+	results, errs := manager.CallBatch(operations)
+
+	if repo.batchCount != 1 || repo.fetchCount != 0 {
+		t.Fatalf("Expected to have fetched once in batch, but got %d batches and %d fetches", repo.batchCount, repo.fetchCount)
+	}
+
+	if errs[0] != nil || results[0] == nil || results[0].result != "tracked result" {
+		t.Fatalf("Expected to have \"tracked result\" as result, but got \"%v\" and \"%v\"", results[0], errs[0])
+	}
+
+	exptectedExpirationErr := newExpirationError("target", "expired")
+	if errs[1] == nil || errs[1].Error() != exptectedExpirationErr.Error() || results[1] != nil {
+		t.Fatalf("Expected to have \"%v\" error, but got \"%v\"", exptectedExpirationErr, errs[1])
+	}
+
+	exptectedStillRunningErr := newStillRunningError("target", "running")
+	if errs[2] == nil || errs[2].Error() != exptectedStillRunningErr.Error() || results[2] != nil {
+		t.Fatalf("Expected to have \"%v\" error, but got \"%v\"", exptectedStillRunningErr, errs[2])
+	}
+
+	if errs[3] != nil || results[3] == nil || results[3].result != "result" {
+		t.Fatalf("Expected to have \"result\" as result, but got \"%v\" and \"%v\"", results[3], errs[3])
+	}
+
+	if errs[4] == nil || errs[4].Error() != "Boom!" || results[4] != nil {
+		t.Fatalf("Expected to have \"Boom!\" error, but got \"%v\"", errs[4])
+	}
+}
+
+func TestCallBatchWithoutBatchRepository(t *testing.T) {
+	manager := New(newEmptyRepository())
+
+	operations := []Operation[mockedPayload, mockedResult, *mockedCtx]{
+		newMockedOperation("first", "target", newMockedPayload("payload"), time.Now(), 5*time.Second, 10*time.Second, newMockedResultFn("first result")),
+		newMockedOperation("second", "target", newMockedPayload("payload"), time.Now(), 5*time.Second, 10*time.Second, newMockedResultFn("second result")),
+	}
+	results, errs := manager.CallBatch(operations)
+
+	for i, expected := range []string{"first result", "second result"} {
+		if errs[i] != nil || results[i] == nil || results[i].result != expected {
+			t.Fatalf("Expected to have \"%s\" as result, but got \"%v\" and \"%v\"", expected, results[i], errs[i])
+		}
+	}
+}
+
+func TestCallAsync(t *testing.T) {
+	manager := New(newEmptyRepository())
+	operation := newMockedOperation(
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		time.Now(),
+		5*time.Second,
+		10*time.Second,
+		newMockedResultFn("Ok!"),
+	)
+	result, err := manager.CallAsync(operation).Wait()
+
+	if err != nil {
+		t.Fatalf("Expected to have no error, but got \"%v\"", err)
+	}
+
+	if result == nil || result.result != "Ok!" {
+		t.Fatalf("Expected to have \"Ok!\" as result, but got \"%v\"", result)
+	}
+}
+
+func TestCallAsyncAlreadyStillRunning(t *testing.T) {
+	trackedOperation := NewTrackedOperation[mockedPayload, mockedResult](
+		Running,
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		time.Now().Add(-10*time.Second),
+		time.Now().Add(-7*time.Second),
+		time.Now().Add(5*time.Second),
+		time.Now().Add(7*time.Second),
+		nil,
+		nil,
+	)
+	manager := New(newTrackedOperationRepository(trackedOperation))
+	operation := newMockedOperation(
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		time.Now(),
+		5*time.Second,
+		10*time.Second,
+		newMockedResultFn("result"),
+	)
+	future := manager.CallAsync(operation)
+
+	if !future.Ready() {
+		t.Fatalf("Expected future to be ready, but wasn't")
+	}
+
+	result, err := future.Wait()
+
+	exptectedErr := newStillRunningError("target", "key")
+	if err == nil || err.Error() != exptectedErr.Error() {
+		t.Fatalf("Expected to have \"%v\" error, but got \"%v\"", exptectedErr, err)
+	}
+
+	if result != nil {
+		t.Fatalf("Expected to have no result, but got \"%s\"", result.result)
+	}
+}
+
+func TestCallAsyncRepositoryPanic(t *testing.T) {
+	manager := New[mockedPayload, mockedResult, *mockedCtx](&panickingRepository{})
+	operation := newMockedOperation(
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		time.Now(),
+		5*time.Second,
+		10*time.Second,
+		newMockedResultFn("Ok!"),
+	)
+
+	_, err := manager.CallAsync(operation).Wait()
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value() != "database is down" {
+		t.Fatalf("Expected to have panic error, but got \"%v\"", err)
+	}
+}
+
+func TestCallRepositoryPanic(t *testing.T) {
+	manager := New[mockedPayload, mockedResult, *mockedCtx](&panickingRepository{})
+	operation := newMockedOperation(
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		time.Now(),
+		5*time.Second,
+		10*time.Second,
+		newMockedResultFn("Ok!"),
+	)
+
+	_, err := manager.Call(operation)
+	_, errs := manager.CallBatch([]Operation[mockedPayload, mockedResult, *mockedCtx]{operation})
+
+	for _, err := range []error{err, errs[0]} {
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) || panicErr.Value() != "database is down" {
+			t.Fatalf("Expected to have panic error, but got \"%v\"", err)
+		}
+	}
+
+	defer func() {
+		if recovery := recover(); recovery != "database is down" {
+			t.Fatalf("Expected to panic again, but got \"%v\"", recovery)
+		}
+	}()
+
+	New[mockedPayload, mockedResult, *mockedCtx](&panickingRepository{}, WithRepanic()).Call(operation)
+	t.Fatalf("Expected to panic")
+}
+
+func TestCallAsyncWithoutWorkers(t *testing.T) {
+	for _, workers := range []int{0, -1} {
+		manager := New(newEmptyRepository(), WithWorkers(workers))
+		operation := newMockedOperation(
+			"key",
+			"target",
+			newMockedPayload("payload"),
+			time.Now(),
+			5*time.Second,
+			10*time.Second,
+			newMockedResultFn("Ok!"),
+		)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		result, err := manager.CallAsync(operation).WaitContext(ctx)
+		cancel()
+
+		if err != nil || result == nil || result.result != "Ok!" {
+			t.Fatalf("Expected to have \"Ok!\" with %d workers, but got \"%v\" and \"%v\"", workers, result, err)
+		}
+	}
+}
+
+func TestCallAsyncBoundedWorkers(t *testing.T) {
+	manager := New(newEmptyRepository(), WithWorkers(1))
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	blockingFn := func() (*mockedResult, error) {
+		started <- struct{}{}
+		<-release
+		return newMockedResult("Ok!"), nil
+	}
+
+	first := manager.CallAsync(newMockedOperation("first", "target", newMockedPayload("payload"), time.Now(), 5*time.Second, 10*time.Second, blockingFn))
+	second := manager.CallAsync(newMockedOperation("second", "target", newMockedPayload("payload"), time.Now(), 5*time.Second, 10*time.Second, blockingFn))
+
+	<-started
+	select {
+	case <-started:
+		t.Fatalf("Expected only one operation to be running at a time, but both started")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if first.Ready() || second.Ready() {
+		t.Fatalf("Expected no future to be ready before operations are released")
+	}
+
+	close(release)
+	for _, future := range []*Future[mockedResult]{first, second} {
+		if result, err := future.Wait(); err != nil || result.result != "Ok!" {
+			t.Fatalf("Expected to have \"Ok!\" as result, but got \"%v\" and \"%v\"", result, err)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	trackedOperation := NewTrackedOperation(
+		Finished,
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		time.Now().Add(-10*time.Second),
+		time.Now().Add(-5*time.Second),
+		time.Now().Add(5*time.Second),
+		time.Now().Add(10*time.Second),
+		newMockedResult("tracked result"),
+		nil,
+	)
+	manager := New(newTrackedOperationRepository(trackedOperation))
+
+	result, err := manager.Lookup("key", "target")
+	if err != nil || result == nil || result.result != "tracked result" {
+		t.Fatalf("Expected to have \"tracked result\" as result, but got \"%v\" and \"%v\"", result, err)
+	}
+
+	result, err = manager.Lookup("another key", "target")
+	exptectedErr := newNotFoundError("target", "another key")
+	if err == nil || err.Error() != exptectedErr.Error() || result != nil {
+		t.Fatalf("Expected to have \"%v\" error, but got \"%v\"", exptectedErr, err)
+	}
+}
+
+func TestLookupUnsupported(t *testing.T) {
+	manager := New(newEmptyRepository())
+
+	if _, err := manager.Lookup("key", "target"); !errors.Is(err, ErrLookupUnsupported) {
+		t.Fatalf("Expected to have lookup unsupported error, but got \"%v\"", err)
+	}
+}
+
+func TestLookupFailed(t *testing.T) {
+	trackedOperation := NewTrackedOperation[mockedPayload, mockedResult](
+		Failed,
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		time.Now().Add(-10*time.Second),
+		time.Now().Add(-5*time.Second),
+		time.Now().Add(5*time.Second),
+		time.Now().Add(10*time.Second),
+		nil,
+		errors.New("Boom!"),
+	)
+	manager := New(newTrackedOperationRepository(trackedOperation))
+
+	result, err := manager.Lookup("key", "target")
+	if err == nil || err.Error() != "Boom!" || result != nil {
+		t.Fatalf("Expected to have \"Boom!\" error, but got \"%v\"", err)
+	}
+}
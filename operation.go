@@ -2,6 +2,8 @@ package ana
 
 import "time"
 
+// Operation is what managers call idempotently. Expiration is counted from
+// ReferenceTime, and zero Timeout or Expiration means no deadline at all.
 type Operation[P any, R any, C SessionCtx[P, R]] interface {
 	Key() string
 	Target() string
@@ -11,3 +13,32 @@ type Operation[P any, R any, C SessionCtx[P, R]] interface {
 	Expiration() time.Duration
 	Call(C) (*R, error)
 }
+
+// referencedOperation is implemented by operations taking their reference time
+// from the clock of managers calling them, unless they were given one.
+type referencedOperation interface {
+	referenceFrom(Clock) time.Time
+}
+
+type operationWrapper interface {
+	unwrapOperation() any
+}
+
+// OperationAs finds, among given operation and those wrapped by it, the first
+// one implementing T. Repositories use it to look for optional interfaces,
+// since managers may wrap operations, as when normalizing their keys.
+func OperationAs[T any](operation any) (T, bool) {
+	for {
+		if found, ok := operation.(T); ok {
+			return found, true
+		}
+
+		wrapper, ok := operation.(operationWrapper)
+		if !ok {
+			var zero T
+			return zero, false
+		}
+
+		operation = wrapper.unwrapOperation()
+	}
+}
@@ -2,6 +2,7 @@ package ana
 
 import (
 	"errors"
+	"sync/atomic"
 	"time"
 )
 
@@ -100,16 +101,25 @@ func newMockedPanicFn(message string) func() (*mockedResult, error) {
 type mockedCtx struct {
 	SuccessCount uint
 	FailCount    uint
+	ExtendCount  atomic.Uint64
+	Err          error
 }
 
 func newMockedCtx() *mockedCtx {
-	return &mockedCtx{0, 0}
+	return &mockedCtx{}
 }
 
-func (ctx *mockedCtx) Success(*TrackedOperation[mockedPayload, mockedResult]) {
+func (ctx *mockedCtx) Success(*TrackedOperation[mockedPayload, mockedResult]) error {
 	ctx.SuccessCount += 1
+	return ctx.Err
 }
 
-func (ctx *mockedCtx) Fail(*TrackedOperation[mockedPayload, mockedResult]) {
+func (ctx *mockedCtx) Fail(*TrackedOperation[mockedPayload, mockedResult]) error {
 	ctx.FailCount += 1
+	return ctx.Err
+}
+
+func (ctx *mockedCtx) Extend(time.Duration) error {
+	ctx.ExtendCount.Add(1)
+	return nil
 }
@@ -0,0 +1,60 @@
+package ana
+
+const DefaultWorkers int = 10
+
+type Option func(*options)
+
+// WithWorkers bounds how many operations called by Manager.CallAsync may run
+// at the same time. Values below 1 keep DefaultWorkers.
+func WithWorkers(workers int) Option {
+	return func(options *options) {
+		if workers >= 1 {
+			options.workers = workers
+		}
+	}
+}
+
+// WithClock sets the clock used on every timeout and expiration decision. By
+// default it is repository clock, if it is a ClockProvider, or SystemClock.
+func WithClock(clock Clock) Option {
+	return func(options *options) {
+		options.clock = clock
+	}
+}
+
+// WithKeyPolicy sets which idempotency keys are accepted. By default keys are
+// required to be non empty and at most DefaultKeyMaxLength characters long.
+func WithKeyPolicy(policy KeyPolicy) Option {
+	return func(options *options) {
+		options.keyPolicy = policy
+	}
+}
+
+// WithRepanic makes panicking operations panic again, with the same value, once
+// their failure is recorded, instead of returning a PanicError. Operations called
+// by Manager.CallAsync then panic on their own goroutine.
+func WithRepanic() Option {
+	return func(options *options) {
+		options.repanic = true
+	}
+}
+
+type options struct {
+	workers   int
+	clock     Clock
+	keyPolicy KeyPolicy
+	repanic   bool
+}
+
+func newOptions(opts []Option) *options {
+	options := &options{
+		workers:   DefaultWorkers,
+		keyPolicy: KeyPolicy{MaxLength: DefaultKeyMaxLength},
+	}
+
+	for _, option := range opts {
+		option(options)
+	}
+
+	return options
+}
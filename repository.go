@@ -4,3 +4,18 @@ type IdempotencyRepository[P any, R any, C SessionCtx[P, R]] interface {
 	FetchOrStart(Operation[P, R, C]) *TrackedOperation[P, R]
 	NewSession(Operation[P, R, C]) *Session[P, R, C]
 }
+
+type BatchIdempotencyRepository[P any, R any, C SessionCtx[P, R]] interface {
+	IdempotencyRepository[P, R, C]
+	FetchOrStartBatch([]Operation[P, R, C]) []*TrackedOperation[P, R]
+}
+
+type LookupRepository[P any, R any] interface {
+	Lookup(key string, target string) *TrackedOperation[P, R]
+}
+
+// LookupSupporter is implemented by repository wrappers, which are always
+// LookupRepository, telling whether the repository they wrap supports lookups.
+type LookupSupporter interface {
+	SupportsLookup() bool
+}
@@ -0,0 +1,160 @@
+package bolt
+
+import (
+	"path/filepath"
+	"time"
+
+	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/anatest"
+	"github.com/dalthon/ana/internal/testutil"
+	bolt "go.etcd.io/bbolt"
+
+	"testing"
+)
+
+type boltCtx = *BoltContext[testutil.DebugPayload, testutil.DebugResult]
+
+func TestBoltContextSuperseded(t *testing.T) {
+	db := newDatabase(t)
+	repo := NewBoltRepository[testutil.DebugPayload, testutil.DebugResult](db)
+	operation := testutil.NewMockedOperation[boltCtx]("key", "target", "payload", "result", true)
+
+	trackedOperation := repo.FetchOrStart(operation)
+	session := repo.NewSession(operation)
+	testutil.AssertErrorNil(t, putSideEffect(session.Context.Tx, "stale"))
+	session.Context.Attempt -= 1
+
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	err := session.Context.Success(trackedOperation)
+	if _, ok := err.(*a.SupersededError); !ok {
+		t.Fatalf("Expected to have superseded error, but got \"%v\"", err)
+	}
+
+	refreshedOperation := repo.Lookup("key", "target")
+	testutil.AssertEqual(t, refreshedOperation.Status, a.Running)
+	testutil.AssertNil(t, refreshedOperation.Result)
+	testutil.AssertEqual(t, "", getSideEffect(db))
+}
+
+func TestBoltRepositoryDeleteExpired(t *testing.T) {
+	clock := a.NewFakeClock(time.Now())
+	repo := NewBoltRepository[testutil.DebugPayload, testutil.DebugResult](newDatabase(t), WithClock(clock))
+
+	for _, key := range []string{"first", "second", "third"} {
+		operation := testutil.NewMockedOperation[boltCtx](key, "target", "payload", "result", true)
+		trackedOperation := repo.FetchOrStart(operation)
+		trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+		testutil.AssertErrorNil(t, repo.NewSession(operation).Context.Success(trackedOperation))
+	}
+	repo.FetchOrStart(testutil.NewMockedOperation[boltCtx]("running", "target", "payload", "result", true))
+
+	testutil.AssertEqual(t, int64(0), repo.DeleteExpired(a.Finished, 2))
+
+	clock.Advance(time.Hour)
+	testutil.AssertEqual(t, int64(2), repo.DeleteExpired(a.Finished, 2))
+	testutil.AssertEqual(t, int64(1), repo.DeleteExpired(a.Finished, 2))
+	testutil.AssertEqual(t, int64(0), repo.DeleteExpired(a.Finished, 2))
+	testutil.AssertEqual(t, a.Running, repo.Lookup("running", "target").Status)
+}
+
+func TestBoltRepositoryFailExpiredStillRunning(t *testing.T) {
+	clock := a.NewFakeClock(time.Now())
+	repo := NewBoltRepository[testutil.DebugPayload, testutil.DebugResult](newDatabase(t), WithClock(clock))
+
+	for _, key := range []string{"first", "second", "third"} {
+		repo.FetchOrStart(testutil.NewMockedOperation[boltCtx](key, "target", "payload", "result", true))
+	}
+
+	testutil.AssertEqual(t, int64(0), repo.FailExpiredStillRunning(2))
+
+	clock.Advance(time.Hour)
+	testutil.AssertEqual(t, int64(2), repo.FailExpiredStillRunning(2))
+	testutil.AssertEqual(t, int64(1), repo.FailExpiredStillRunning(2))
+	testutil.AssertEqual(t, int64(0), repo.FailExpiredStillRunning(2))
+
+	failedOperation := repo.Lookup("first", "target")
+	testutil.AssertEqual(t, a.Failed, failedOperation.Status)
+	testutil.AssertEqual(t, "Operation expired", failedOperation.Err.Error())
+}
+
+func TestBoltRepositoryFailTimedOutStillRunning(t *testing.T) {
+	clock := a.NewFakeClock(time.Now())
+	repo := NewBoltRepository[testutil.DebugPayload, testutil.DebugResult](newDatabase(t), WithClock(clock))
+
+	for _, key := range []string{"first", "second", "third"} {
+		repo.FetchOrStart(testutil.NewMockedOperation[boltCtx](key, "target", "payload", "result", true))
+	}
+
+	testutil.AssertEqual(t, int64(0), repo.FailTimedOutStillRunning(2))
+
+	clock.Advance(2 * time.Minute)
+	testutil.AssertEqual(t, int64(2), repo.FailTimedOutStillRunning(2))
+	testutil.AssertEqual(t, int64(1), repo.FailTimedOutStillRunning(2))
+	testutil.AssertEqual(t, int64(0), repo.FailTimedOutStillRunning(2))
+
+	failedOperation := repo.Lookup("first", "target")
+	testutil.AssertEqual(t, a.Failed, failedOperation.Status)
+	testutil.AssertEqual(t, "Operation timed out", failedOperation.Err.Error())
+}
+
+func TestBoltRepositorySuite(t *testing.T) {
+	anatest.RunRepositorySuite(t, func(t *testing.T) *anatest.Backend[*BoltContext[anatest.Payload, anatest.Result]] {
+		db := newDatabase(t)
+
+		return &anatest.Backend[*BoltContext[anatest.Payload, anatest.Result]]{
+			Repository: NewBoltRepository[anatest.Payload, anatest.Result](db),
+			Write: func(ctx *BoltContext[anatest.Payload, anatest.Result], value string) error {
+				bucket, err := ctx.Tx.CreateBucketIfNotExists([]byte("marks"))
+				if err != nil {
+					return err
+				}
+
+				return bucket.Put([]byte(value), []byte(value))
+			},
+			Written: func(value string) bool {
+				var written bool
+				db.View(func(tx *bolt.Tx) error {
+					if bucket := tx.Bucket([]byte("marks")); bucket != nil {
+						written = bucket.Get([]byte(value)) != nil
+					}
+
+					return nil
+				})
+
+				return written
+			},
+		}
+	})
+}
+
+func newDatabase(t *testing.T) *bolt.DB {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "ana.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func putSideEffect(tx *bolt.Tx, value string) error {
+	bucket, err := tx.CreateBucketIfNotExists([]byte("side_effects"))
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte("value"), []byte(value))
+}
+
+func getSideEffect(db *bolt.DB) string {
+	var value string
+	db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte("side_effects")); bucket != nil {
+			value = string(bucket.Get([]byte("value")))
+		}
+
+		return nil
+	})
+
+	return value
+}
@@ -0,0 +1,41 @@
+package bolt
+
+import (
+	a "github.com/dalthon/ana"
+)
+
+const defaultBucket string = "tracked_operations"
+
+type Option func(*config)
+
+func WithBucket(bucket string) Option {
+	return func(config *config) {
+		config.bucket = bucket
+	}
+}
+
+// WithClock sets the clock used by repository on every timeout and expiration
+// decision.
+func WithClock(clock a.Clock) Option {
+	return func(config *config) {
+		config.clock = clock
+	}
+}
+
+type config struct {
+	bucket string
+	clock  a.Clock
+}
+
+func newConfig(options []Option) *config {
+	config := &config{
+		bucket: defaultBucket,
+		clock:  a.SystemClock,
+	}
+
+	for _, option := range options {
+		option(config)
+	}
+
+	return config
+}
@@ -0,0 +1,80 @@
+package bolt
+
+import (
+	"errors"
+
+	a "github.com/dalthon/ana"
+	bolt "go.etcd.io/bbolt"
+)
+
+type BoltContext[P any, R any] struct {
+	db      *bolt.DB
+	bucket  string
+	clock   a.Clock
+	Tx      *bolt.Tx
+	Attempt int64
+}
+
+func NewBoltContext[P any, R any](tx *bolt.Tx) *BoltContext[P, R] {
+	return &BoltContext[P, R]{bucket: defaultBucket, clock: a.SystemClock, Tx: tx}
+}
+
+func (ctx *BoltContext[P, R]) Success(operation *a.TrackedOperation[P, R]) error {
+	now := ctx.clock.Now()
+	if !operation.Expiration.IsZero() && now.After(operation.Expiration) {
+		operation.Err = errors.New("Operation expired")
+		return ctx.Fail(operation)
+	}
+
+	bucket := ctx.Tx.Bucket([]byte(ctx.bucket))
+	record := getRecord(bucket, operation.Key, operation.Target)
+	if record == nil || record.Attempt != ctx.Attempt {
+		ctx.Tx.Rollback()
+		return a.NewSupersededError(operation.Target, operation.Key)
+	}
+
+	record.Status = a.Finished
+	record.Payload = encode(operation.Payload)
+	record.Result = encode(operation.Result)
+	record.FinishedAt = now
+	record.ErrorMessage = ""
+
+	if err := putRecord(bucket, record); err != nil {
+		ctx.Tx.Rollback()
+		return err
+	}
+
+	return ctx.Tx.Commit()
+}
+
+// CommitWork commits operation transaction as is.
+func (ctx *BoltContext[P, R]) CommitWork() error {
+	return ctx.Tx.Commit()
+}
+
+// bbolt has no savepoints, so everything written by operation is discarded
+// and its failure is recorded on a transaction of its own.
+func (ctx *BoltContext[P, R]) Fail(operation *a.TrackedOperation[P, R]) error {
+	if err := ctx.Tx.Rollback(); err != nil {
+		return err
+	}
+
+	if ctx.db == nil {
+		return errors.New("Context can not record failures")
+	}
+
+	return ctx.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(ctx.bucket))
+		record := getRecord(bucket, operation.Key, operation.Target)
+		if record == nil || record.Attempt != ctx.Attempt {
+			return a.NewSupersededError(operation.Target, operation.Key)
+		}
+
+		now := ctx.clock.Now()
+		record.Payload = encode(operation.Payload)
+		record.Result = nil
+		record.Timeout = now
+
+		return putRecord(bucket, failRecord(record, now, operation.Err.Error()))
+	})
+}
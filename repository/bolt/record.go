@@ -0,0 +1,110 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"time"
+
+	a "github.com/dalthon/ana"
+	bolt "go.etcd.io/bbolt"
+)
+
+// record is how a tracked operation is stored, with payload and result
+// already encoded, so that it can be read without knowing their types.
+type record struct {
+	Status        a.TrackedOperationStatus
+	Key           string
+	Target        string
+	Payload       []byte
+	ReferenceTime time.Time
+	StartedAt     time.Time
+	FinishedAt    time.Time
+	Timeout       time.Time
+	Expiration    time.Time
+	Result        []byte
+	ErrorMessage  string
+	ErrorCount    int64
+	Attempt       int64
+}
+
+// Target comes first, so that operations of a target are stored together.
+func recordKey(key string, target string) []byte {
+	return []byte(target + "\x00" + key)
+}
+
+func getRecord(bucket *bolt.Bucket, key string, target string) *record {
+	encoded := bucket.Get(recordKey(key, target))
+	if encoded == nil {
+		return nil
+	}
+
+	return decodeRecord(encoded)
+}
+
+func putRecord(bucket *bolt.Bucket, record *record) error {
+	return bucket.Put(recordKey(record.Key, record.Target), encode(record))
+}
+
+func decodeRecord(encoded []byte) *record {
+	var record record
+	if gob.NewDecoder(bytes.NewBuffer(encoded)).Decode(&record) != nil {
+		panic("Could not decode data.")
+	}
+
+	return &record
+}
+
+func encode[S any](value *S) []byte {
+	if value == nil {
+		return []byte{}
+	}
+
+	var buffer bytes.Buffer
+	encoder := gob.NewEncoder(&buffer)
+
+	if encoder.Encode(value) != nil {
+		panic("Could not encode data.")
+	}
+
+	return buffer.Bytes()
+}
+
+func decode[S any](encoded []byte) *S {
+	if len(encoded) == 0 {
+		return nil
+	}
+
+	decoder := gob.NewDecoder(bytes.NewBuffer(encoded))
+	var decoded S
+
+	if decoder.Decode(&decoded) != nil {
+		panic("Could not decode data.")
+	}
+
+	return &decoded
+}
+
+func recordToTrackedOperation[P any, R any](record *record) *a.TrackedOperation[P, R] {
+	if record == nil {
+		return nil
+	}
+
+	operation := &a.TrackedOperation[P, R]{
+		Status:        record.Status,
+		Key:           record.Key,
+		Target:        record.Target,
+		Payload:       decode[P](record.Payload),
+		ReferenceTime: record.ReferenceTime,
+		StartedAt:     record.StartedAt,
+		Timeout:       record.Timeout,
+		Expiration:    record.Expiration,
+		Result:        decode[R](record.Result),
+	}
+
+	if record.ErrorMessage != "" {
+		operation.Err = errors.New(record.ErrorMessage)
+	}
+
+	return operation
+}
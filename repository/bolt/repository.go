@@ -0,0 +1,261 @@
+package bolt
+
+import (
+	"sort"
+	"time"
+
+	a "github.com/dalthon/ana"
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltRepository keeps tracked operations on a bbolt bucket. A session holds
+// the single bbolt write transaction while its operation runs, so sessions are
+// serialized and operations must not start write transactions of their own.
+type BoltRepository[P any, R any] struct {
+	db     *bolt.DB
+	config *config
+}
+
+func NewBoltRepository[P any, R any](db *bolt.DB, options ...Option) *BoltRepository[P, R] {
+	config := newConfig(options)
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(config.bucket))
+		return err
+	})
+
+	if err != nil {
+		panic(err)
+	}
+
+	return &BoltRepository[P, R]{db: db, config: config}
+}
+
+func (repo *BoltRepository[P, R]) Clock() a.Clock {
+	return repo.config.clock
+}
+
+func (repo *BoltRepository[P, R]) FetchOrStart(operation a.Operation[P, R, *BoltContext[P, R]]) *a.TrackedOperation[P, R] {
+	var trackedOperation *a.TrackedOperation[P, R]
+
+	repo.update(func(bucket *bolt.Bucket) error {
+		var err error
+		trackedOperation, err = repo.fetchOrStart(bucket, operation)
+		return err
+	})
+
+	return trackedOperation
+}
+
+// FetchOrStartBatch fetches or starts all given operations on a single
+// transaction.
+func (repo *BoltRepository[P, R]) FetchOrStartBatch(operations []a.Operation[P, R, *BoltContext[P, R]]) []*a.TrackedOperation[P, R] {
+	trackedOperations := make([]*a.TrackedOperation[P, R], len(operations))
+
+	repo.update(func(bucket *bolt.Bucket) error {
+		for i, operation := range operations {
+			trackedOperation, err := repo.fetchOrStart(bucket, operation)
+			if err != nil {
+				return err
+			}
+
+			trackedOperations[i] = trackedOperation
+		}
+
+		return nil
+	})
+
+	return trackedOperations
+}
+
+func (repo *BoltRepository[P, R]) Lookup(key string, target string) *a.TrackedOperation[P, R] {
+	var record *record
+
+	err := repo.db.View(func(tx *bolt.Tx) error {
+		record = getRecord(tx.Bucket([]byte(repo.config.bucket)), key, target)
+		return nil
+	})
+
+	if err != nil {
+		panic(err)
+	}
+
+	return recordToTrackedOperation[P, R](record)
+}
+
+func (repo *BoltRepository[P, R]) NewSession(operation a.Operation[P, R, *BoltContext[P, R]]) *a.Session[P, R, *BoltContext[P, R]] {
+	var attempt int64
+
+	repo.update(func(bucket *bolt.Bucket) error {
+		record := getRecord(bucket, operation.Key(), operation.Target())
+		if record == nil {
+			return nil
+		}
+
+		now := repo.config.clock.Now()
+		record.Attempt += 1
+		record.Status = a.Running
+		record.StartedAt = now
+		record.Timeout = timeAfter(now, operation.Timeout())
+		attempt = record.Attempt
+
+		return putRecord(bucket, record)
+	})
+
+	tx, err := repo.db.Begin(true)
+	if err != nil {
+		panic(err)
+	}
+
+	boltContext := NewBoltContext[P, R](tx)
+	boltContext.db = repo.db
+	boltContext.bucket = repo.config.bucket
+	boltContext.clock = repo.config.clock
+	boltContext.Attempt = attempt
+
+	return a.NewSession(operation, boltContext)
+}
+
+func (repo *BoltRepository[P, R]) FailTimedOutStillRunning(count int) int64 {
+	now := repo.config.clock.Now()
+
+	return repo.reap(
+		count,
+		func(record *record) (time.Time, bool) {
+			return record.Timeout, record.Status == a.Running && !record.Timeout.IsZero() && record.Timeout.Before(now)
+		},
+		func(bucket *bolt.Bucket, record *record) error {
+			return putRecord(bucket, failRecord(record, now, "Operation timed out"))
+		},
+	)
+}
+
+func (repo *BoltRepository[P, R]) FailExpiredStillRunning(count int) int64 {
+	now := repo.config.clock.Now()
+
+	return repo.reap(
+		count,
+		func(record *record) (time.Time, bool) {
+			return record.Expiration, record.Status == a.Running && !record.Expiration.IsZero() && record.Expiration.Before(now)
+		},
+		func(bucket *bolt.Bucket, record *record) error {
+			return putRecord(bucket, failRecord(record, now, "Operation expired"))
+		},
+	)
+}
+
+func (repo *BoltRepository[P, R]) DeleteExpired(status a.TrackedOperationStatus, count int) int64 {
+	now := repo.config.clock.Now()
+
+	return repo.reap(
+		count,
+		func(record *record) (time.Time, bool) {
+			return record.Expiration, record.Status == status && !record.Expiration.IsZero() && record.Expiration.Before(now)
+		},
+		func(bucket *bolt.Bucket, record *record) error {
+			return bucket.Delete(recordKey(record.Key, record.Target))
+		},
+	)
+}
+
+func (repo *BoltRepository[P, R]) fetchOrStart(bucket *bolt.Bucket, operation a.Operation[P, R, *BoltContext[P, R]]) (*a.TrackedOperation[P, R], error) {
+	if record := getRecord(bucket, operation.Key(), operation.Target()); record != nil {
+		return recordToTrackedOperation[P, R](record), nil
+	}
+
+	now := repo.config.clock.Now()
+	record := &record{
+		Status:        a.Running,
+		Key:           operation.Key(),
+		Target:        operation.Target(),
+		Payload:       encode(operation.Payload()),
+		ReferenceTime: operation.ReferenceTime(),
+		StartedAt:     now,
+		Timeout:       timeAfter(now, operation.Timeout()),
+		Expiration:    timeAfter(operation.ReferenceTime(), operation.Expiration()),
+	}
+
+	if err := putRecord(bucket, record); err != nil {
+		return nil, err
+	}
+
+	trackedOperation := recordToTrackedOperation[P, R](record)
+	trackedOperation.Status = a.Ready
+
+	return trackedOperation, nil
+}
+
+// bbolt has no secondary indexes, so reapers scan the whole bucket and change
+// up to count matching records, those due first.
+func (repo *BoltRepository[P, R]) reap(
+	count int,
+	matches func(*record) (time.Time, bool),
+	change func(*bolt.Bucket, *record) error,
+) int64 {
+	var changed int64
+
+	repo.update(func(bucket *bolt.Bucket) error {
+		type candidate struct {
+			record *record
+			due    time.Time
+		}
+
+		candidates := []candidate{}
+		err := bucket.ForEach(func(_, encoded []byte) error {
+			record := decodeRecord(encoded)
+			if due, ok := matches(record); ok {
+				candidates = append(candidates, candidate{record, due})
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].due.Before(candidates[j].due)
+		})
+
+		for i := 0; i < len(candidates) && i < count; i++ {
+			if err := change(bucket, candidates[i].record); err != nil {
+				return err
+			}
+
+			changed += 1
+		}
+
+		return nil
+	})
+
+	return changed
+}
+
+func (repo *BoltRepository[P, R]) update(fn func(*bolt.Bucket) error) {
+	err := repo.db.Update(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket([]byte(repo.config.bucket)))
+	})
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+func failRecord(record *record, now time.Time, message string) *record {
+	record.Status = a.Failed
+	record.FinishedAt = now
+	record.ErrorCount += 1
+	record.ErrorMessage = message
+
+	return record
+}
+
+// timeAfter encodes zero durations as zero times.
+func timeAfter(base time.Time, duration time.Duration) time.Time {
+	if duration == time.Duration(0) {
+		return time.Time{}
+	}
+
+	return base.Add(duration)
+}
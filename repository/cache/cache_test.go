@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/anatest"
+	"github.com/dalthon/ana/internal/testutil"
+	"github.com/redis/go-redis/v9"
+
+	"testing"
+)
+
+func TestCachedRepositoryFinished(t *testing.T) {
+	clock := a.NewFakeClock(time.Now())
+	backing := newMemoryRepository(clock)
+	repo := NewCachedRepository[debugPayload, debugResult, *mockedCtx](backing, NewLRU[debugPayload, debugResult](10, clock))
+	manager := a.New[debugPayload, debugResult, *mockedCtx](repo)
+
+	result, err := manager.Call(newMockedOperation("key", clock.Now(), "result"))
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, "result", result.Value)
+	testutil.AssertEqual(t, 1, backing.fetchCount)
+
+	for i := 0; i < 3; i++ {
+		result, err = manager.Call(newMockedOperation("key", clock.Now(), "another result"))
+		testutil.AssertErrorNil(t, err)
+		testutil.AssertEqual(t, "result", result.Value)
+	}
+	testutil.AssertEqual(t, 2, backing.fetchCount)
+
+	lookedUp, err := manager.Lookup("key", "target")
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, "result", lookedUp.Value)
+	testutil.AssertEqual(t, 0, backing.lookupCount)
+
+	clock.Advance(time.Hour)
+	repo.FetchOrStart(newMockedOperation("key", clock.Now(), "result"))
+	testutil.AssertEqual(t, 3, backing.fetchCount)
+}
+
+func TestCachedRepositoryRunning(t *testing.T) {
+	clock := a.NewFakeClock(time.Now())
+	backing := newMemoryRepository(clock)
+	repo := NewCachedRepository[debugPayload, debugResult, *mockedCtx](backing, NewLRU[debugPayload, debugResult](10, clock))
+
+	operation := newMockedOperation("key", clock.Now(), "result")
+	testutil.AssertEqual(t, a.Ready, repo.FetchOrStart(operation).Status)
+	testutil.AssertEqual(t, a.Running, repo.FetchOrStart(operation).Status)
+	testutil.AssertEqual(t, a.Running, repo.Lookup("key", "target").Status)
+	testutil.AssertEqual(t, 2, backing.fetchCount)
+	testutil.AssertEqual(t, 1, backing.lookupCount)
+}
+
+func TestCachedRepositoryLookupUnsupported(t *testing.T) {
+	clock := a.NewFakeClock(time.Now())
+	backing := &unlookableRepository{newMemoryRepository(clock)}
+	repo := NewCachedRepository[debugPayload, debugResult, *mockedCtx](backing, NewLRU[debugPayload, debugResult](10, clock))
+	manager := a.New[debugPayload, debugResult, *mockedCtx](repo)
+
+	_, err := manager.Lookup("key", "target")
+	testutil.AssertEqual(t, true, errors.Is(err, a.ErrLookupUnsupported))
+}
+
+func TestCachedRepositoryBatch(t *testing.T) {
+	clock := a.NewFakeClock(time.Now())
+	backing := &batchMemoryRepository{newMemoryRepository(clock)}
+	repo := NewCachedRepository[debugPayload, debugResult, *mockedCtx](backing, NewLRU[debugPayload, debugResult](10, clock))
+	manager := a.New[debugPayload, debugResult, *mockedCtx](repo)
+
+	_, err := manager.Call(newMockedOperation("finished", clock.Now(), "result"))
+	testutil.AssertErrorNil(t, err)
+	repo.FetchOrStart(newMockedOperation("finished", clock.Now(), "result"))
+
+	trackedOperations := repo.FetchOrStartBatch([]a.Operation[debugPayload, debugResult, *mockedCtx]{
+		newMockedOperation("new", clock.Now(), "result"),
+		newMockedOperation("finished", clock.Now(), "result"),
+	})
+
+	testutil.AssertEqual(t, a.Ready, trackedOperations[0].Status)
+	testutil.AssertEqual(t, a.Finished, trackedOperations[1].Status)
+	testutil.AssertEqual(t, "result", trackedOperations[1].Result.Value)
+	testutil.AssertEqual(t, 1, backing.batchCount)
+	testutil.AssertEqual(t, 2, backing.fetchCount)
+}
+
+func TestCachedRepositorySuite(t *testing.T) {
+	type fakeCtx = *anatest.FakeContext[anatest.Payload, anatest.Result]
+
+	anatest.RunRepositorySuite(t, func(t *testing.T) *anatest.Backend[fakeCtx] {
+		backing := anatest.NewFakeRepository[anatest.Payload, anatest.Result](nil)
+		store := NewLRU[anatest.Payload, anatest.Result](10, backing.Clock())
+
+		return &anatest.Backend[fakeCtx]{
+			Repository: NewCachedRepository[anatest.Payload, anatest.Result, fakeCtx](backing, store),
+			Write: func(ctx fakeCtx, value string) error {
+				ctx.Write(value)
+				return nil
+			},
+			Written: func(value string) bool {
+				return slices.Contains(backing.Written(), value)
+			},
+		}
+	})
+}
+
+func TestLRU(t *testing.T) {
+	clock := a.NewFakeClock(time.Now())
+	lru := NewLRU[debugPayload, debugResult](2, clock)
+
+	for _, key := range []string{"first", "second"} {
+		lru.Set(&a.TrackedOperation[debugPayload, debugResult]{Status: a.Finished, Key: key, Target: "target"})
+	}
+
+	if lru.Get("first", "target") == nil {
+		t.Fatal("Expected first to be cached")
+	}
+
+	lru.Set(&a.TrackedOperation[debugPayload, debugResult]{Status: a.Finished, Key: "third", Target: "target"})
+	testutil.AssertEqual(t, 2, lru.Len())
+	testutil.AssertNil(t, lru.Get("second", "target"))
+
+	lru.Set(&a.TrackedOperation[debugPayload, debugResult]{
+		Status:     a.Finished,
+		Key:        "expiring",
+		Target:     "target",
+		Expiration: clock.Now().Add(time.Minute),
+	})
+
+	if lru.Get("expiring", "target") == nil {
+		t.Fatal("Expected expiring to be cached")
+	}
+
+	clock.Advance(time.Minute)
+	testutil.AssertNil(t, lru.Get("expiring", "target"))
+	testutil.AssertEqual(t, 1, lru.Len())
+}
+
+func TestRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	clock := a.NewFakeClock(time.Now())
+	store := NewRedisStore[debugPayload, debugResult](client, "ana:", clock)
+
+	testutil.AssertNil(t, store.Get("key", "target"))
+
+	store.Set(&a.TrackedOperation[debugPayload, debugResult]{
+		Status:     a.Finished,
+		Key:        "key",
+		Target:     "target",
+		Payload:    &debugPayload{"payload"},
+		Expiration: clock.Now().Add(time.Minute),
+		Result:     &debugResult{"result"},
+	})
+
+	cached := store.Get("key", "target")
+	testutil.AssertEqual(t, a.Finished, cached.Status)
+	testutil.AssertEqual(t, "payload", cached.Payload.Value)
+	testutil.AssertEqual(t, "result", cached.Result.Value)
+
+	server.FastForward(time.Minute)
+	testutil.AssertNil(t, store.Get("key", "target"))
+
+	server.Close()
+	testutil.AssertNil(t, store.Get("key", "target"))
+}
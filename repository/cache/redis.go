@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"time"
+
+	a "github.com/dalthon/ana"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisEntry is how a tracked operation is stored on Redis, since its Err can
+// not be encoded and is always nil for finished ones.
+type redisEntry[P any, R any] struct {
+	Key           string
+	Target        string
+	Payload       *P
+	ReferenceTime time.Time
+	StartedAt     time.Time
+	Timeout       time.Time
+	Expiration    time.Time
+	Result        *R
+}
+
+// RedisStore is a Store shared by every process using the same Redis, whose
+// entries are set to expire along with their tracked operations.
+//
+// Being a cache, Redis failures are taken as misses instead of errors.
+type RedisStore[P any, R any] struct {
+	client redis.UniversalClient
+	prefix string
+	clock  a.Clock
+}
+
+func NewRedisStore[P any, R any](client redis.UniversalClient, prefix string, clock a.Clock) *RedisStore[P, R] {
+	return &RedisStore[P, R]{client: client, prefix: prefix, clock: clock}
+}
+
+func (store *RedisStore[P, R]) Get(key string, target string) *a.TrackedOperation[P, R] {
+	encoded, err := store.client.Get(context.Background(), store.redisKey(key, target)).Bytes()
+	if err != nil {
+		return nil
+	}
+
+	var entry redisEntry[P, R]
+	if gob.NewDecoder(bytes.NewBuffer(encoded)).Decode(&entry) != nil {
+		return nil
+	}
+
+	operation := &a.TrackedOperation[P, R]{
+		Status:        a.Finished,
+		Key:           entry.Key,
+		Target:        entry.Target,
+		Payload:       entry.Payload,
+		ReferenceTime: entry.ReferenceTime,
+		StartedAt:     entry.StartedAt,
+		Timeout:       entry.Timeout,
+		Expiration:    entry.Expiration,
+		Result:        entry.Result,
+	}
+
+	if isExpired(operation, store.clock) {
+		return nil
+	}
+
+	return operation
+}
+
+func (store *RedisStore[P, R]) Set(operation *a.TrackedOperation[P, R]) {
+	var ttl time.Duration
+	if !operation.Expiration.IsZero() {
+		if ttl = operation.Expiration.Sub(store.clock.Now()); ttl <= 0 {
+			return
+		}
+	}
+
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(&redisEntry[P, R]{
+		Key:           operation.Key,
+		Target:        operation.Target,
+		Payload:       operation.Payload,
+		ReferenceTime: operation.ReferenceTime,
+		StartedAt:     operation.StartedAt,
+		Timeout:       operation.Timeout,
+		Expiration:    operation.Expiration,
+		Result:        operation.Result,
+	})
+
+	if err != nil {
+		panic("Could not encode data.")
+	}
+
+	store.client.Set(context.Background(), store.redisKey(operation.Key, operation.Target), buffer.Bytes(), ttl)
+}
+
+func (store *RedisStore[P, R]) redisKey(key string, target string) string {
+	return store.prefix + storeKey(key, target)
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	a "github.com/dalthon/ana"
+)
+
+// CachedRepository wraps an IdempotencyRepository, answering fetch or start
+// calls for finished operations from a Store, so that the wrapped repository
+// is consulted only on misses or while operations are not finished.
+type CachedRepository[P any, R any, C a.SessionCtx[P, R]] struct {
+	repository a.IdempotencyRepository[P, R, C]
+	store      Store[P, R]
+}
+
+func NewCachedRepository[P any, R any, C a.SessionCtx[P, R]](repository a.IdempotencyRepository[P, R, C], store Store[P, R]) *CachedRepository[P, R, C] {
+	return &CachedRepository[P, R, C]{repository: repository, store: store}
+}
+
+// Clock is the clock of cached repository.
+func (repo *CachedRepository[P, R, C]) Clock() a.Clock {
+	if provider, ok := repo.repository.(a.ClockProvider); ok {
+		return provider.Clock()
+	}
+
+	return a.SystemClock
+}
+
+func (repo *CachedRepository[P, R, C]) FetchOrStart(operation a.Operation[P, R, C]) *a.TrackedOperation[P, R] {
+	if trackedOperation := repo.store.Get(operation.Key(), operation.Target()); trackedOperation != nil {
+		return trackedOperation
+	}
+
+	return repo.remember(repo.repository.FetchOrStart(operation))
+}
+
+func (repo *CachedRepository[P, R, C]) FetchOrStartBatch(operations []a.Operation[P, R, C]) []*a.TrackedOperation[P, R] {
+	trackedOperations := make([]*a.TrackedOperation[P, R], len(operations))
+
+	misses := []int{}
+	missing := []a.Operation[P, R, C]{}
+	for i, operation := range operations {
+		if trackedOperation := repo.store.Get(operation.Key(), operation.Target()); trackedOperation != nil {
+			trackedOperations[i] = trackedOperation
+			continue
+		}
+
+		misses = append(misses, i)
+		missing = append(missing, operation)
+	}
+
+	if len(missing) == 0 {
+		return trackedOperations
+	}
+
+	var fetched []*a.TrackedOperation[P, R]
+	if batchRepository, ok := repo.repository.(a.BatchIdempotencyRepository[P, R, C]); ok {
+		fetched = batchRepository.FetchOrStartBatch(missing)
+	} else {
+		fetched = make([]*a.TrackedOperation[P, R], len(missing))
+		for i, operation := range missing {
+			fetched[i] = repo.repository.FetchOrStart(operation)
+		}
+	}
+
+	for i, trackedOperation := range fetched {
+		trackedOperations[misses[i]] = repo.remember(trackedOperation)
+	}
+
+	return trackedOperations
+}
+
+func (repo *CachedRepository[P, R, C]) Lookup(key string, target string) *a.TrackedOperation[P, R] {
+	if trackedOperation := repo.store.Get(key, target); trackedOperation != nil {
+		return trackedOperation
+	}
+
+	lookupRepository, ok := repo.repository.(a.LookupRepository[P, R])
+	if !ok {
+		return nil
+	}
+
+	return repo.remember(lookupRepository.Lookup(key, target))
+}
+
+// SupportsLookup tells whether wrapped repository supports lookups, since
+// cached operations alone can not tell operations apart from missing ones.
+func (repo *CachedRepository[P, R, C]) SupportsLookup() bool {
+	if supporter, ok := repo.repository.(a.LookupSupporter); ok {
+		return supporter.SupportsLookup()
+	}
+
+	_, ok := repo.repository.(a.LookupRepository[P, R])
+	return ok
+}
+
+func (repo *CachedRepository[P, R, C]) NewSession(operation a.Operation[P, R, C]) *a.Session[P, R, C] {
+	return repo.repository.NewSession(operation)
+}
+
+func (repo *CachedRepository[P, R, C]) remember(trackedOperation *a.TrackedOperation[P, R]) *a.TrackedOperation[P, R] {
+	if trackedOperation != nil && trackedOperation.Status == a.Finished {
+		repo.store.Set(trackedOperation)
+	}
+
+	return trackedOperation
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"time"
+
+	a "github.com/dalthon/ana"
+)
+
+type debugPayload struct {
+	Value string
+}
+
+type debugResult struct {
+	Value string
+}
+
+type mockedCtx struct {
+	repo *memoryRepository
+}
+
+func (ctx *mockedCtx) Success(operation *a.TrackedOperation[debugPayload, debugResult]) error {
+	finished := *operation
+	finished.Status = a.Finished
+	ctx.repo.operations[storeKey(operation.Key, operation.Target)] = &finished
+
+	return nil
+}
+
+func (ctx *mockedCtx) Fail(operation *a.TrackedOperation[debugPayload, debugResult]) error {
+	failed := *operation
+	failed.Status = a.Failed
+	ctx.repo.operations[storeKey(operation.Key, operation.Target)] = &failed
+
+	return nil
+}
+
+type mockedOperation struct {
+	key           string
+	referenceTime time.Time
+	expiration    time.Duration
+	result        string
+}
+
+func newMockedOperation(key string, referenceTime time.Time, result string) *mockedOperation {
+	return &mockedOperation{key: key, referenceTime: referenceTime, expiration: time.Minute, result: result}
+}
+
+func (o *mockedOperation) Key() string               { return o.key }
+func (o *mockedOperation) Target() string            { return "target" }
+func (o *mockedOperation) Payload() *debugPayload    { return &debugPayload{"payload"} }
+func (o *mockedOperation) ReferenceTime() time.Time  { return o.referenceTime }
+func (o *mockedOperation) Timeout() time.Duration    { return time.Minute }
+func (o *mockedOperation) Expiration() time.Duration { return o.expiration }
+
+func (o *mockedOperation) Call(ctx *mockedCtx) (*debugResult, error) {
+	return &debugResult{o.result}, nil
+}
+
+type memoryRepository struct {
+	clock       a.Clock
+	operations  map[string]*a.TrackedOperation[debugPayload, debugResult]
+	fetchCount  int
+	batchCount  int
+	lookupCount int
+}
+
+func newMemoryRepository(clock a.Clock) *memoryRepository {
+	return &memoryRepository{
+		clock:      clock,
+		operations: map[string]*a.TrackedOperation[debugPayload, debugResult]{},
+	}
+}
+
+func (repo *memoryRepository) Clock() a.Clock {
+	return repo.clock
+}
+
+func (repo *memoryRepository) FetchOrStart(operation a.Operation[debugPayload, debugResult, *mockedCtx]) *a.TrackedOperation[debugPayload, debugResult] {
+	repo.fetchCount += 1
+
+	id := storeKey(operation.Key(), operation.Target())
+	if trackedOperation, ok := repo.operations[id]; ok {
+		return trackedOperation
+	}
+
+	trackedOperation := &a.TrackedOperation[debugPayload, debugResult]{
+		Status:        a.Running,
+		Key:           operation.Key(),
+		Target:        operation.Target(),
+		Payload:       operation.Payload(),
+		ReferenceTime: operation.ReferenceTime(),
+		StartedAt:     repo.clock.Now(),
+		Timeout:       repo.clock.Now().Add(operation.Timeout()),
+		Expiration:    operation.ReferenceTime().Add(operation.Expiration()),
+	}
+	repo.operations[id] = trackedOperation
+
+	ready := *trackedOperation
+	ready.Status = a.Ready
+
+	return &ready
+}
+
+func (repo *memoryRepository) Lookup(key string, target string) *a.TrackedOperation[debugPayload, debugResult] {
+	repo.lookupCount += 1
+
+	return repo.operations[storeKey(key, target)]
+}
+
+func (repo *memoryRepository) NewSession(operation a.Operation[debugPayload, debugResult, *mockedCtx]) *a.Session[debugPayload, debugResult, *mockedCtx] {
+	return a.NewSession(operation, &mockedCtx{repo})
+}
+
+// unlookableRepository hides every method but those IdempotencyRepository has.
+type unlookableRepository struct {
+	a.IdempotencyRepository[debugPayload, debugResult, *mockedCtx]
+}
+
+type batchMemoryRepository struct {
+	*memoryRepository
+}
+
+func (repo *batchMemoryRepository) FetchOrStartBatch(operations []a.Operation[debugPayload, debugResult, *mockedCtx]) []*a.TrackedOperation[debugPayload, debugResult] {
+	repo.batchCount += 1
+
+	trackedOperations := make([]*a.TrackedOperation[debugPayload, debugResult], len(operations))
+	for i, operation := range operations {
+		trackedOperations[i] = repo.FetchOrStart(operation)
+		repo.fetchCount -= 1
+	}
+
+	return trackedOperations
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	a "github.com/dalthon/ana"
+)
+
+// Store keeps finished tracked operations until their expiration, or until
+// evicted when they never expire.
+type Store[P any, R any] interface {
+	Get(key string, target string) *a.TrackedOperation[P, R]
+	Set(operation *a.TrackedOperation[P, R])
+}
+
+type lruEntry[P any, R any] struct {
+	id        string
+	operation *a.TrackedOperation[P, R]
+}
+
+// LRU is an in process Store holding up to capacity tracked operations, evicting
+// the least recently used ones first.
+type LRU[P any, R any] struct {
+	mutex    sync.Mutex
+	capacity int
+	clock    a.Clock
+	entries  *list.List
+	index    map[string]*list.Element
+}
+
+func NewLRU[P any, R any](capacity int, clock a.Clock) *LRU[P, R] {
+	return &LRU[P, R]{
+		capacity: capacity,
+		clock:    clock,
+		entries:  list.New(),
+		index:    map[string]*list.Element{},
+	}
+}
+
+func (lru *LRU[P, R]) Get(key string, target string) *a.TrackedOperation[P, R] {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	element, ok := lru.index[storeKey(key, target)]
+	if !ok {
+		return nil
+	}
+
+	entry := element.Value.(*lruEntry[P, R])
+	if isExpired(entry.operation, lru.clock) {
+		lru.remove(element)
+		return nil
+	}
+
+	lru.entries.MoveToFront(element)
+	return entry.operation
+}
+
+func (lru *LRU[P, R]) Set(operation *a.TrackedOperation[P, R]) {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	id := storeKey(operation.Key, operation.Target)
+	if element, ok := lru.index[id]; ok {
+		element.Value.(*lruEntry[P, R]).operation = operation
+		lru.entries.MoveToFront(element)
+		return
+	}
+
+	lru.index[id] = lru.entries.PushFront(&lruEntry[P, R]{id, operation})
+	for lru.entries.Len() > lru.capacity {
+		lru.remove(lru.entries.Back())
+	}
+}
+
+func (lru *LRU[P, R]) Len() int {
+	lru.mutex.Lock()
+	defer lru.mutex.Unlock()
+
+	return lru.entries.Len()
+}
+
+func (lru *LRU[P, R]) remove(element *list.Element) {
+	lru.entries.Remove(element)
+	delete(lru.index, element.Value.(*lruEntry[P, R]).id)
+}
+
+func storeKey(key string, target string) string {
+	return target + "\x00" + key
+}
+
+func isExpired[P any, R any](operation *a.TrackedOperation[P, R], clock a.Clock) bool {
+	return !operation.Expiration.IsZero() && !clock.Now().Before(operation.Expiration)
+}
@@ -0,0 +1,107 @@
+package mysql
+
+import (
+	"strings"
+	"text/template"
+
+	a "github.com/dalthon/ana"
+)
+
+const defaultTable string = "tracked_operations"
+
+type Option func(*config)
+
+func WithTable(table string) Option {
+	return func(config *config) {
+		config.table = table
+	}
+}
+
+// WithClock sets the clock used on expiration decisions taken in Go.
+func WithClock(clock a.Clock) Option {
+	return func(config *config) {
+		config.clock = clock
+	}
+}
+
+type config struct {
+	table string
+	clock a.Clock
+}
+
+func newConfig(options []Option) *config {
+	config := &config{
+		table: defaultTable,
+		clock: a.SystemClock,
+	}
+
+	for _, option := range options {
+		option(config)
+	}
+
+	return config
+}
+
+func (config *config) identifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (config *config) names() map[string]any {
+	return map[string]any{
+		"Table":           config.identifier(config.table),
+		"Migrations":      config.identifier(config.table + "_migrations"),
+		"TimeoutIndex":    config.identifier(config.table + "_timeout_idx"),
+		"ExpirationIndex": config.identifier(config.table + "_expiration_idx"),
+	}
+}
+
+func (config *config) lock() string {
+	return "ana." + config.table
+}
+
+func (config *config) render(text string) string {
+	var builder strings.Builder
+
+	tmpl := template.Must(template.New("").Parse(text))
+	if err := tmpl.Execute(&builder, config.names()); err != nil {
+		panic(err)
+	}
+
+	return builder.String()
+}
+
+type queries struct {
+	insertTrackedOperation   string
+	selectTrackedOperation   string
+	lookup                   string
+	claimTrackedOperation    string
+	selectAttempt            string
+	lockTrackOperation       string
+	timedOutStillRunning     string
+	expiredStillRunning      string
+	failTrackedOperationBy   string
+	expiredTrackedOperations string
+	deleteTrackedOperation   string
+	finishTrackedOperation   string
+	failTrackedOperation     string
+}
+
+var defaultQueries *queries = newQueries(newConfig(nil))
+
+func newQueries(config *config) *queries {
+	return &queries{
+		insertTrackedOperation:   config.render(insertTrackedOperationQuery),
+		selectTrackedOperation:   config.render(selectTrackedOperationQuery),
+		lookup:                   config.render(lookupQuery),
+		claimTrackedOperation:    config.render(claimTrackedOperationQuery),
+		selectAttempt:            config.render(selectAttemptQuery),
+		lockTrackOperation:       config.render(lockTrackOperationQuery),
+		timedOutStillRunning:     config.render(timedOutStillRunningQuery),
+		expiredStillRunning:      config.render(expiredStillRunningQuery),
+		failTrackedOperationBy:   config.render(failTrackedOperationByQuery),
+		expiredTrackedOperations: config.render(expiredTrackedOperationsQuery),
+		deleteTrackedOperation:   config.render(deleteTrackedOperationQuery),
+		finishTrackedOperation:   config.render(finishTrackedOperationQuery),
+		failTrackedOperation:     config.render(failTrackedOperationQuery),
+	}
+}
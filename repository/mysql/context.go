@@ -0,0 +1,127 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	a "github.com/dalthon/ana"
+)
+
+var finishTrackedOperationQuery string = `
+  UPDATE {{.Table}}
+  SET
+    payload       = ?,
+    result        = ?,
+    finished_at   = NOW(6),
+    status        = 'finished',
+    error_message = NULL
+  WHERE
+    ` + "`key`" + ` = ? AND target = ? AND attempt = ?;
+`
+
+var failTrackedOperationQuery string = `
+  UPDATE {{.Table}}
+  SET
+    payload       = ?,
+    result        = NULL,
+    finished_at   = NOW(6),
+    status        = 'failed',
+    timeout       = NOW(6),
+    error_message = ?,
+    error_count   = error_count + 1
+  WHERE
+    ` + "`key`" + ` = ? AND target = ? AND attempt = ?;
+`
+
+// Operation writes happen within a savepoint, playing the role of the nested
+// transaction of PgxContext, so that they can be rolled back while operation
+// failure is still recorded on the outer transaction.
+var (
+	savepointQuery         string = "SAVEPOINT operation;"
+	releaseSavepointQuery  string = "RELEASE SAVEPOINT operation;"
+	rollbackSavepointQuery string = "ROLLBACK TO SAVEPOINT operation;"
+)
+
+type MysqlContext[P any, R any] struct {
+	queries *queries
+	clock   a.Clock
+	Tx      *sql.Tx
+	Context context.Context
+	Attempt int64
+}
+
+func NewMysqlContext[P any, R any](tx *sql.Tx, context context.Context) *MysqlContext[P, R] {
+	return &MysqlContext[P, R]{queries: defaultQueries, clock: a.SystemClock, Tx: tx, Context: context}
+}
+
+func (ctx *MysqlContext[P, R]) Success(operation *a.TrackedOperation[P, R]) error {
+	if !operation.Expiration.IsZero() && ctx.clock.Now().After(operation.Expiration) {
+		operation.Err = errors.New("Operation expired")
+		return ctx.Fail(operation)
+	}
+
+	if _, err := ctx.Tx.ExecContext(ctx.Context, releaseSavepointQuery); err != nil {
+		ctx.Tx.Rollback()
+		return err
+	}
+
+	return ctx.finish(
+		operation,
+		ctx.queries.finishTrackedOperation,
+		serialize(operation.Payload),
+		serialize(operation.Result),
+		operation.Key,
+		operation.Target,
+		ctx.Attempt,
+	)
+}
+
+func (ctx *MysqlContext[P, R]) Fail(operation *a.TrackedOperation[P, R]) error {
+	for _, query := range []string{rollbackSavepointQuery, releaseSavepointQuery} {
+		if _, err := ctx.Tx.ExecContext(ctx.Context, query); err != nil {
+			ctx.Tx.Rollback()
+			return err
+		}
+	}
+
+	return ctx.finish(
+		operation,
+		ctx.queries.failTrackedOperation,
+		serialize(operation.Payload),
+		operation.Err.Error(),
+		operation.Key,
+		operation.Target,
+		ctx.Attempt,
+	)
+}
+
+// CommitWork releases operation savepoint before committing.
+func (ctx *MysqlContext[P, R]) CommitWork() error {
+	if _, err := ctx.Tx.ExecContext(ctx.Context, releaseSavepointQuery); err != nil {
+		ctx.Tx.Rollback()
+		return err
+	}
+
+	return ctx.Tx.Commit()
+}
+
+// finish commits only when the update found operation attempt row.
+func (ctx *MysqlContext[P, R]) finish(operation *a.TrackedOperation[P, R], query string, args ...any) error {
+	result, err := ctx.Tx.ExecContext(ctx.Context, query, args...)
+	if err != nil {
+		ctx.Tx.Rollback()
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		ctx.Tx.Rollback()
+		if err != nil {
+			return err
+		}
+
+		return a.NewSupersededError(operation.Target, operation.Key)
+	}
+
+	return ctx.Tx.Commit()
+}
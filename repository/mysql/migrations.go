@@ -0,0 +1,172 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var getLockQuery string = `
+  SELECT GET_LOCK(?, -1);
+`
+
+var releaseLockQuery string = `
+  SELECT RELEASE_LOCK(?);
+`
+
+var createMigrationsTableQuery string = `
+  CREATE TABLE IF NOT EXISTS {{.Migrations}} (
+    version    bigint       NOT NULL,
+    name       varchar(255) NOT NULL,
+    applied_at datetime(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    PRIMARY KEY(version)
+  );
+`
+
+var appliedMigrationsQuery string = `
+  SELECT version FROM {{.Migrations}};
+`
+
+var insertMigrationQuery string = `
+  INSERT INTO {{.Migrations}} (version, name)
+  VALUES (?, ?);
+`
+
+type migration struct {
+	version    int64
+	name       string
+	statements []string
+}
+
+// Migrate applies every embedded migration not yet recorded on database.
+//
+// Since MySQL commits every DDL statement right away, migrations run holding a
+// named lock instead of a transaction, so it is still safe to call it from
+// many replicas at once. It must receive the same options given to
+// NewMysqlRepository.
+func Migrate(ctx context.Context, db *sql.DB, options ...Option) error {
+	config := newConfig(options)
+
+	migrations, err := loadMigrations(config)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, getLockQuery, config.lock()).Scan(&locked); err != nil {
+		return err
+	}
+
+	if locked.Int64 != 1 {
+		return errors.New("Could not lock migrations")
+	}
+	defer conn.ExecContext(ctx, releaseLockQuery, config.lock())
+
+	if _, err := conn.ExecContext(ctx, config.render(createMigrationsTableQuery)); err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(ctx, conn, config)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if applied[migration.version] {
+			continue
+		}
+
+		for _, statement := range migration.statements {
+			if _, err := conn.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("migration %s failed: %w", migration.name, err)
+			}
+		}
+
+		_, err := conn.ExecContext(ctx, config.render(insertMigrationQuery), migration.version, migration.name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn, config *config) (map[int64]bool, error) {
+	rows, err := conn.QueryContext(ctx, config.render(appliedMigrationsQuery))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]bool{}
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+func loadMigrations(config *config) ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+
+		version, err := strconv.ParseInt(strings.SplitN(name, "_", 2)[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration name %s", entry.Name())
+		}
+
+		script, err := fs.ReadFile(migrationFiles, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, migration{version, name, splitStatements(config.render(string(script)))})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
+
+// Statements are run one by one, so that migrations do not depend on
+// multiStatements being enabled on connection.
+func splitStatements(script string) []string {
+	statements := []string{}
+	for _, statement := range strings.Split(script, ";\n") {
+		if statement = strings.TrimSuffix(strings.TrimSpace(statement), ";"); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+
+	return statements
+}
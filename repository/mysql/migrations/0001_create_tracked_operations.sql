@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS {{.Table}} (
+  reference_time datetime(6)                                     NOT NULL,
+  started_at     datetime(6)                                     NOT NULL,
+  finished_at    datetime(6),
+  timeout        datetime(6),
+  expiration     datetime(6),
+  error_count    integer                                         NOT NULL DEFAULT 0,
+  status         enum('ready', 'running', 'finished', 'failed') NOT NULL DEFAULT 'running',
+  target         varchar(255)                                    NOT NULL,
+  `key`          varchar(255)                                    NOT NULL,
+  payload        longblob                                        NOT NULL,
+  result         longblob,
+  error_message  text,
+  attempt        bigint                                          NOT NULL DEFAULT 0,
+
+  PRIMARY KEY(target, `key`),
+  INDEX {{.TimeoutIndex}} (status, timeout),
+  INDEX {{.ExpirationIndex}} (status, expiration)
+);
@@ -0,0 +1,183 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"os"
+
+	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/anatest"
+	"github.com/dalthon/ana/internal/testutil"
+	_ "github.com/go-sql-driver/mysql"
+
+	"testing"
+)
+
+type mysqlCtx = *MysqlContext[testutil.DebugPayload, testutil.DebugResult]
+
+func TestMysqlRepositoryLookup(t *testing.T) {
+	repo := NewMysqlRepository[testutil.DebugPayload, testutil.DebugResult](newDatabase(t))
+	operation := testutil.NewMockedOperation[mysqlCtx]("key", "target", "payload", "result", true)
+
+	testutil.AssertNil(t, repo.Lookup("key", "target"))
+
+	trackedOperation := repo.FetchOrStart(operation)
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	session := repo.NewSession(operation)
+	testutil.AssertErrorNil(t, session.Context.Success(trackedOperation))
+
+	lookedUpOperation := repo.Lookup("key", "target")
+	testutil.AssertEqual(t, lookedUpOperation.Status, a.Finished)
+	testutil.AssertEqual(t, lookedUpOperation.Result.Value, "result")
+	testutil.AssertNil(t, repo.Lookup("key", "another target"))
+}
+
+func TestMysqlContextSuperseded(t *testing.T) {
+	db := newDatabase(t)
+	repo := NewMysqlRepository[testutil.DebugPayload, testutil.DebugResult](db)
+	operation := testutil.NewMockedOperation[mysqlCtx]("key", "target", "payload", "result", true)
+
+	trackedOperation := repo.FetchOrStart(operation)
+	session := repo.NewSession(operation)
+
+	_, err := session.Context.Tx.Exec("INSERT INTO side_effects (value) VALUES ('stale');")
+	testutil.AssertErrorNil(t, err)
+	_, err = session.Context.Tx.Exec("UPDATE tracked_operations SET attempt = attempt + 1;")
+	testutil.AssertErrorNil(t, err)
+
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	err = session.Context.Success(trackedOperation)
+	if _, ok := err.(*a.SupersededError); !ok {
+		t.Fatalf("Expected to have superseded error, but got \"%v\"", err)
+	}
+
+	refreshedOperation := repo.Lookup("key", "target")
+	testutil.AssertEqual(t, refreshedOperation.Status, a.Running)
+	testutil.AssertNil(t, refreshedOperation.Result)
+	testutil.AssertEqual(t, int64(0), countSideEffects(db))
+}
+
+func TestMysqlRepositoryDeleteExpired(t *testing.T) {
+	db := newDatabase(t)
+	repo := NewMysqlRepository[testutil.DebugPayload, testutil.DebugResult](db)
+
+	for _, key := range []string{"first", "second", "third"} {
+		operation := testutil.NewMockedOperation[mysqlCtx](key, "target", "payload", "result", true)
+		trackedOperation := repo.FetchOrStart(operation)
+		trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+		testutil.AssertErrorNil(t, repo.NewSession(operation).Context.Success(trackedOperation))
+	}
+	repo.FetchOrStart(testutil.NewMockedOperation[mysqlCtx]("running", "target", "payload", "result", true))
+
+	testutil.AssertEqual(t, int64(0), repo.DeleteExpired(a.Finished, 2))
+
+	exec(t, db, "UPDATE tracked_operations SET expiration = NOW(6) - INTERVAL 1 SECOND;")
+	testutil.AssertEqual(t, int64(2), repo.DeleteExpired(a.Finished, 2))
+	testutil.AssertEqual(t, int64(1), repo.DeleteExpired(a.Finished, 2))
+	testutil.AssertEqual(t, int64(0), repo.DeleteExpired(a.Finished, 2))
+	testutil.AssertEqual(t, a.Running, repo.Lookup("running", "target").Status)
+}
+
+func TestMysqlRepositoryFailExpiredStillRunning(t *testing.T) {
+	db := newDatabase(t)
+	repo := NewMysqlRepository[testutil.DebugPayload, testutil.DebugResult](db)
+
+	for _, key := range []string{"first", "second", "third"} {
+		repo.FetchOrStart(testutil.NewMockedOperation[mysqlCtx](key, "target", "payload", "result", true))
+	}
+
+	testutil.AssertEqual(t, int64(0), repo.FailExpiredStillRunning(2))
+
+	exec(t, db, "UPDATE tracked_operations SET expiration = NOW(6) - INTERVAL 1 SECOND;")
+	testutil.AssertEqual(t, int64(2), repo.FailExpiredStillRunning(2))
+	testutil.AssertEqual(t, int64(1), repo.FailExpiredStillRunning(2))
+	testutil.AssertEqual(t, int64(0), repo.FailExpiredStillRunning(2))
+
+	failedOperation := repo.Lookup("first", "target")
+	testutil.AssertEqual(t, a.Failed, failedOperation.Status)
+	testutil.AssertEqual(t, "Operation expired", failedOperation.Err.Error())
+}
+
+func TestMysqlRepositoryFailTimedOutStillRunning(t *testing.T) {
+	db := newDatabase(t)
+	repo := NewMysqlRepository[testutil.DebugPayload, testutil.DebugResult](db)
+
+	for _, key := range []string{"first", "second", "third"} {
+		repo.FetchOrStart(testutil.NewMockedOperation[mysqlCtx](key, "target", "payload", "result", true))
+	}
+
+	testutil.AssertEqual(t, int64(0), repo.FailTimedOutStillRunning(2))
+
+	exec(t, db, "UPDATE tracked_operations SET timeout = NOW(6) - INTERVAL 1 SECOND;")
+	testutil.AssertEqual(t, int64(2), repo.FailTimedOutStillRunning(2))
+	testutil.AssertEqual(t, int64(1), repo.FailTimedOutStillRunning(2))
+	testutil.AssertEqual(t, int64(0), repo.FailTimedOutStillRunning(2))
+
+	failedOperation := repo.Lookup("first", "target")
+	testutil.AssertEqual(t, a.Failed, failedOperation.Status)
+	testutil.AssertEqual(t, "Operation timed out", failedOperation.Err.Error())
+}
+
+func TestSplitStatements(t *testing.T) {
+	statements := splitStatements("CREATE TABLE a (id int);\n\nCREATE INDEX b ON a (id);\n")
+	testutil.AssertEqual(t, 2, len(statements))
+	testutil.AssertEqual(t, "CREATE TABLE a (id int)", statements[0])
+	testutil.AssertEqual(t, "CREATE INDEX b ON a (id)", statements[1])
+}
+
+// Tests touching database run only when MYSQL_DATABASE_URL is set, as in
+// docker-compose.yml, since there is no embedded mysqld.
+func TestMysqlRepositorySuite(t *testing.T) {
+	anatest.RunRepositorySuite(t, func(t *testing.T) *anatest.Backend[*MysqlContext[anatest.Payload, anatest.Result]] {
+		db := newDatabase(t)
+
+		return &anatest.Backend[*MysqlContext[anatest.Payload, anatest.Result]]{
+			Repository: NewMysqlRepository[anatest.Payload, anatest.Result](db),
+			Write: func(ctx *MysqlContext[anatest.Payload, anatest.Result], value string) error {
+				_, err := ctx.Tx.ExecContext(ctx.Context, "INSERT INTO side_effects (value) VALUES (?);", value)
+				return err
+			},
+			Written: func(value string) bool {
+				var count int64
+				db.QueryRow("SELECT COUNT(*) FROM side_effects WHERE value = ?;", value).Scan(&count)
+				return count > 0
+			},
+		}
+	})
+}
+
+func newDatabase(t *testing.T) *sql.DB {
+	url := os.Getenv("MYSQL_DATABASE_URL")
+	if url == "" {
+		t.Skip("MYSQL_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("mysql", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	exec(t, db, "CREATE TABLE IF NOT EXISTS side_effects (value varchar(255));")
+	exec(t, db, "TRUNCATE side_effects;")
+	exec(t, db, "TRUNCATE tracked_operations;")
+
+	return db
+}
+
+func exec(t *testing.T, db *sql.DB, query string) {
+	if _, err := db.Exec(query); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func countSideEffects(db *sql.DB) int64 {
+	var count int64
+	db.QueryRow("SELECT COUNT(*) FROM side_effects;").Scan(&count)
+
+	return count
+}
@@ -0,0 +1,429 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	a "github.com/dalthon/ana"
+)
+
+// Upserting takes an exclusive lock on the row, whether it was inserted or
+// already there, so that concurrent calls never deadlock on gap locks. It
+// affects one row when inserted and none otherwise.
+var insertTrackedOperationQuery string = `
+  INSERT INTO {{.Table}} (
+    status,
+    ` + "`key`" + `,
+    target,
+    payload,
+    reference_time,
+    started_at,
+    timeout,
+    expiration
+  ) VALUES (
+    'running',
+    ?,
+    ?,
+    ?,
+    ?,
+    NOW(6),
+    DATE_ADD(NOW(6), INTERVAL ? MICROSECOND),
+    DATE_ADD(?,      INTERVAL ? MICROSECOND)
+  )
+  ON DUPLICATE KEY UPDATE attempt = attempt;
+`
+
+var selectTrackedOperationQuery string = `
+  SELECT
+    status,
+    ` + "`key`" + `,
+    target,
+    payload,
+    reference_time,
+    started_at,
+    timeout,
+    expiration,
+    result,
+    error_message
+  FROM {{.Table}}
+  WHERE ` + "`key`" + ` = ? AND target = ?
+  FOR UPDATE;
+`
+
+var lookupQuery string = `
+  SELECT
+    status,
+    ` + "`key`" + `,
+    target,
+    payload,
+    reference_time,
+    started_at,
+    timeout,
+    expiration,
+    result,
+    error_message
+  FROM {{.Table}}
+  WHERE ` + "`key`" + ` = ? AND target = ?;
+`
+
+// Claiming happens outside session transaction, but sessions lock their rows
+// FOR UPDATE while their operations run, and InnoDB has no lock weaker than that
+// which still lets rows be updated, so it waits for any older attempt to finish
+// first, or fails after innodb_lock_wait_timeout. Attempt numbers are still
+// checked when outcomes are recorded, but never fence a running attempt here.
+var claimTrackedOperationQuery string = `
+  UPDATE {{.Table}}
+  SET
+    attempt    = attempt + 1,
+    status     = 'running',
+    started_at = NOW(6),
+    timeout    = DATE_ADD(NOW(6), INTERVAL ? MICROSECOND)
+  WHERE
+    ` + "`key`" + ` = ? AND target = ?;
+`
+
+var selectAttemptQuery string = `
+  SELECT attempt
+  FROM {{.Table}}
+  WHERE ` + "`key`" + ` = ? AND target = ?;
+`
+
+var lockTrackOperationQuery string = `
+  SELECT attempt
+  FROM {{.Table}}
+  WHERE
+    ` + "`key`" + `          = ? AND
+    target         = ? AND
+    reference_time = ?
+  FOR UPDATE;
+`
+
+var timedOutStillRunningQuery string = `
+  SELECT target, ` + "`key`" + `
+  FROM {{.Table}}
+  WHERE
+    status = 'running' AND
+    timeout < NOW(6)
+  ORDER BY timeout ASC
+  LIMIT ?
+  FOR UPDATE SKIP LOCKED;
+`
+
+var expiredStillRunningQuery string = `
+  SELECT target, ` + "`key`" + `
+  FROM {{.Table}}
+  WHERE
+    status = 'running' AND
+    expiration < NOW(6)
+  ORDER BY expiration ASC
+  LIMIT ?
+  FOR UPDATE SKIP LOCKED;
+`
+
+var failTrackedOperationByQuery string = `
+  UPDATE {{.Table}}
+  SET
+    status        = 'failed',
+    finished_at   = NOW(6),
+    error_count   = error_count + 1,
+    error_message = ?
+  WHERE target = ? AND ` + "`key`" + ` = ?;
+`
+
+var expiredTrackedOperationsQuery string = `
+  SELECT target, ` + "`key`" + `
+  FROM {{.Table}}
+  WHERE
+    status = ? AND
+    expiration < NOW(6)
+  ORDER BY expiration ASC
+  LIMIT ?
+  FOR UPDATE SKIP LOCKED;
+`
+
+var deleteTrackedOperationQuery string = `
+  DELETE FROM {{.Table}}
+  WHERE target = ? AND ` + "`key`" + ` = ?;
+`
+
+// MysqlRepository keeps tracked operations on MySQL 8.
+//
+// Its database must be opened with parseTime=true, loc=UTC and time_zone set
+// to '+00:00', and clientFoundRows must not be enabled.
+type MysqlRepository[P any, R any] struct {
+	db      *sql.DB
+	config  *config
+	queries *queries
+}
+
+func NewMysqlRepository[P any, R any](db *sql.DB, options ...Option) *MysqlRepository[P, R] {
+	config := newConfig(options)
+
+	return &MysqlRepository[P, R]{
+		db:      db,
+		config:  config,
+		queries: newQueries(config),
+	}
+}
+
+func (repo *MysqlRepository[P, R]) Clock() a.Clock {
+	return repo.config.clock
+}
+
+func (repo *MysqlRepository[P, R]) FetchOrStart(operation a.Operation[P, R, *MysqlContext[P, R]]) *a.TrackedOperation[P, R] {
+	ctx := context.Background()
+
+	tx := repo.begin(ctx)
+	defer tx.Rollback()
+
+	trackedOperation := repo.fetchOrStart(ctx, tx, operation)
+	if err := tx.Commit(); err != nil {
+		panic(err)
+	}
+
+	return trackedOperation
+}
+
+// FetchOrStartBatch fetches or starts all given operations on a single
+// transaction. Operations are locked sorted by target and key, so that
+// concurrent batches never deadlock each other.
+func (repo *MysqlRepository[P, R]) FetchOrStartBatch(operations []a.Operation[P, R, *MysqlContext[P, R]]) []*a.TrackedOperation[P, R] {
+	ctx := context.Background()
+
+	order := make([]int, len(operations))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		left, right := operations[order[i]], operations[order[j]]
+		if left.Target() != right.Target() {
+			return left.Target() < right.Target()
+		}
+
+		return left.Key() < right.Key()
+	})
+
+	tx := repo.begin(ctx)
+	defer tx.Rollback()
+
+	trackedOperations := make([]*a.TrackedOperation[P, R], len(operations))
+	for _, i := range order {
+		trackedOperations[i] = repo.fetchOrStart(ctx, tx, operations[i])
+	}
+
+	if err := tx.Commit(); err != nil {
+		panic(err)
+	}
+
+	return trackedOperations
+}
+
+func (repo *MysqlRepository[P, R]) Lookup(key string, target string) *a.TrackedOperation[P, R] {
+	rows, err := repo.db.QueryContext(context.Background(), repo.queries.lookup, key, target)
+	if err != nil {
+		panic(err)
+	}
+
+	return rowsToTrackedOperation[P, R](rows)
+}
+
+func (repo *MysqlRepository[P, R]) NewSession(operation a.Operation[P, R, *MysqlContext[P, R]]) *a.Session[P, R, *MysqlContext[P, R]] {
+	ctx := context.Background()
+	attempt := repo.claim(ctx, operation)
+
+	tx := repo.begin(ctx)
+
+	rows, err := tx.QueryContext(
+		ctx,
+		repo.queries.lockTrackOperation,
+		operation.Key(),
+		operation.Target(),
+		operation.ReferenceTime(),
+	)
+	if err != nil {
+		tx.Rollback()
+		panic(err)
+	}
+	rows.Close()
+
+	if _, err := tx.ExecContext(ctx, savepointQuery); err != nil {
+		tx.Rollback()
+		panic(err)
+	}
+
+	mysqlContext := NewMysqlContext[P, R](tx, ctx)
+	mysqlContext.queries = repo.queries
+	mysqlContext.clock = repo.config.clock
+	mysqlContext.Attempt = attempt
+
+	return a.NewSession(operation, mysqlContext)
+}
+
+func (repo *MysqlRepository[P, R]) FailTimedOutStillRunning(count int) int64 {
+	return repo.reap(
+		repo.queries.timedOutStillRunning,
+		[]any{count},
+		repo.queries.failTrackedOperationBy,
+		"Operation timed out",
+	)
+}
+
+func (repo *MysqlRepository[P, R]) FailExpiredStillRunning(count int) int64 {
+	return repo.reap(
+		repo.queries.expiredStillRunning,
+		[]any{count},
+		repo.queries.failTrackedOperationBy,
+		"Operation expired",
+	)
+}
+
+func (repo *MysqlRepository[P, R]) DeleteExpired(status a.TrackedOperationStatus, count int) int64 {
+	return repo.reap(
+		repo.queries.expiredTrackedOperations,
+		[]any{trackedStatusToMysqlStatus(status), count},
+		repo.queries.deleteTrackedOperation,
+	)
+}
+
+func (repo *MysqlRepository[P, R]) fetchOrStart(ctx context.Context, tx *sql.Tx, operation a.Operation[P, R, *MysqlContext[P, R]]) *a.TrackedOperation[P, R] {
+	result, err := tx.ExecContext(
+		ctx,
+		repo.queries.insertTrackedOperation,
+		operation.Key(),
+		operation.Target(),
+		serialize(operation.Payload()),
+		operation.ReferenceTime(),
+		microseconds(operation.Timeout()),
+		operation.ReferenceTime(),
+		microseconds(operation.Expiration()),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		panic(err)
+	}
+
+	rows, err := tx.QueryContext(ctx, repo.queries.selectTrackedOperation, operation.Key(), operation.Target())
+	if err != nil {
+		panic(err)
+	}
+
+	trackedOperation := rowsToTrackedOperation[P, R](rows)
+	if inserted == 1 {
+		trackedOperation.Status = a.Ready
+	}
+
+	return trackedOperation
+}
+
+func (repo *MysqlRepository[P, R]) claim(ctx context.Context, operation a.Operation[P, R, *MysqlContext[P, R]]) int64 {
+	tx := repo.begin(ctx)
+	defer tx.Rollback()
+
+	_, err := tx.ExecContext(
+		ctx,
+		repo.queries.claimTrackedOperation,
+		microseconds(operation.Timeout()),
+		operation.Key(),
+		operation.Target(),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	var attempt int64
+	err = tx.QueryRowContext(ctx, repo.queries.selectAttempt, operation.Key(), operation.Target()).Scan(&attempt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		panic(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		panic(err)
+	}
+
+	return attempt
+}
+
+// MySQL has no UPDATE or DELETE skipping locked rows, so rows to be reaped are
+// selected first and then changed one by one, with target and key appended to
+// given arguments.
+func (repo *MysqlRepository[P, R]) reap(selectQuery string, selectArgs []any, query string, args ...any) int64 {
+	ctx := context.Background()
+
+	tx := repo.begin(ctx)
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, selectQuery, selectArgs...)
+	if err != nil {
+		panic(err)
+	}
+
+	type identity struct{ target, key string }
+
+	identities := []identity{}
+	for rows.Next() {
+		var id identity
+		if err := rows.Scan(&id.target, &id.key); err != nil {
+			panic(err)
+		}
+
+		identities = append(identities, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		panic(err)
+	}
+	rows.Close()
+
+	for _, id := range identities {
+		if _, err := tx.ExecContext(ctx, query, append(append([]any{}, args...), id.target, id.key)...); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		panic(err)
+	}
+
+	return int64(len(identities))
+}
+
+func (repo *MysqlRepository[P, R]) begin(ctx context.Context) *sql.Tx {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return tx
+}
+
+// microseconds is NULL for zero durations, which DATE_ADD then turns to NULL.
+func microseconds(duration time.Duration) sql.NullInt64 {
+	if duration == time.Duration(0) {
+		return sql.NullInt64{}
+	}
+
+	return sql.NullInt64{Int64: duration.Microseconds(), Valid: true}
+}
+
+func trackedStatusToMysqlStatus(status a.TrackedOperationStatus) string {
+	switch status {
+	case a.Ready:
+		return "ready"
+	case a.Running:
+		return "running"
+	case a.Finished:
+		return "finished"
+	case a.Failed:
+		return "failed"
+	default:
+		panic("Dead code")
+	}
+}
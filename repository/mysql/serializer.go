@@ -0,0 +1,103 @@
+package mysql
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+
+	a "github.com/dalthon/ana"
+)
+
+func serialize[S any](value *S) []byte {
+	if value == nil {
+		return []byte{}
+	}
+
+	var buffer bytes.Buffer
+	encoder := gob.NewEncoder(&buffer)
+
+	if encoder.Encode(value) != nil {
+		panic("Could not encode data.")
+	}
+
+	return buffer.Bytes()
+}
+
+func deserialize[S any](encoded []byte) *S {
+	if len(encoded) == 0 {
+		return nil
+	}
+
+	decoder := gob.NewDecoder(bytes.NewBuffer(encoded))
+	var decoded S
+
+	if decoder.Decode(&decoded) != nil {
+		panic("Could not decode data.")
+	}
+
+	return &decoded
+}
+
+func rowsToTrackedOperation[P any, R any](rows *sql.Rows) *a.TrackedOperation[P, R] {
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			panic(err)
+		}
+
+		return nil
+	}
+
+	return scanTrackedOperation[P, R](rows)
+}
+
+func scanTrackedOperation[P any, R any](rows *sql.Rows) *a.TrackedOperation[P, R] {
+	var operation a.TrackedOperation[P, R]
+	var status string
+	var timeout sql.NullTime
+	var expiration sql.NullTime
+	var errorMessage sql.NullString
+	var encodedPayload []byte
+	var encodedResult []byte
+
+	err := rows.Scan(
+		&status,
+		&operation.Key,
+		&operation.Target,
+		&encodedPayload,
+		&operation.ReferenceTime,
+		&operation.StartedAt,
+		&timeout,
+		&expiration,
+		&encodedResult,
+		&errorMessage,
+	)
+
+	if err != nil {
+		panic(err)
+	}
+
+	switch status {
+	case "ready":
+		operation.Status = a.Ready
+	case "running":
+		operation.Status = a.Running
+	case "finished":
+		operation.Status = a.Finished
+	case "failed":
+		operation.Status = a.Failed
+	}
+
+	if errorMessage.String != "" {
+		operation.Err = errors.New(errorMessage.String)
+	}
+
+	operation.Timeout = timeout.Time
+	operation.Expiration = expiration.Time
+	operation.Payload = deserialize[P](encodedPayload)
+	operation.Result = deserialize[R](encodedResult)
+
+	return &operation
+}
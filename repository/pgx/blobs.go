@@ -0,0 +1,50 @@
+package pgx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// BlobStore keeps results too large to be stored on tracked operations table,
+// which then only keeps their names.
+type BlobStore interface {
+	Put(ctx context.Context, name string, data []byte) error
+	Get(ctx context.Context, name string) ([]byte, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// Each attempt writes a blob of its own, so that a superseded attempt never
+// overwrites the result of the one that took it over.
+func blobName(target string, key string, attempt int64) string {
+	hash := sha256.Sum256([]byte(target + "\x00" + key))
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(hash[:]), attempt)
+}
+
+func loadBlob(ctx context.Context, blobs BlobStore, name string) []byte {
+	if blobs == nil {
+		panic("Result is stored on a blob store, but repository has none")
+	}
+
+	data, err := blobs.Get(ctx, name)
+	if err != nil {
+		panic(err)
+	}
+
+	return data
+}
+
+func deleteBlobs(ctx context.Context, blobs BlobStore, names []string) error {
+	if blobs == nil {
+		return nil
+	}
+
+	errs := []error{}
+	for _, name := range names {
+		errs = append(errs, blobs.Delete(ctx, name))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,85 @@
+package blobs
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type store interface {
+	Put(ctx context.Context, name string, data []byte) error
+	Get(ctx context.Context, name string) ([]byte, error)
+	Delete(ctx context.Context, name string) error
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testStore(t, store)
+}
+
+// S3 tests run only when MINIO_ENDPOINT is set, as in docker-compose.yml.
+func TestS3Store(t *testing.T) {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("MINIO_ENDPOINT is not set")
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds: credentials.NewStaticV4(os.Getenv("MINIO_ACCESS_KEY"), os.Getenv("MINIO_SECRET_KEY"), ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if exists, err := client.BucketExists(ctx, "ana"); err != nil {
+		t.Fatal(err)
+	} else if !exists {
+		if err := client.MakeBucket(ctx, "ana", minio.MakeBucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testStore(t, NewS3Store(client, "ana", "results/"))
+}
+
+func testStore(t *testing.T, store store) {
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "blob", []byte("large result")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := store.Get(ctx, "blob")
+	if err != nil || string(data) != "large result" {
+		t.Fatalf("Expected to get \"large result\", but got \"%s\" and %v", data, err)
+	}
+
+	if err := store.Put(ctx, "blob", []byte("another result")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = store.Get(ctx, "blob")
+	if err != nil || string(data) != "another result" {
+		t.Fatalf("Expected to get \"another result\", but got \"%s\" and %v", data, err)
+	}
+
+	if err := store.Delete(ctx, "blob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Delete(ctx, "blob"); err != nil {
+		t.Fatalf("Expected deleting twice to succeed, but got %v", err)
+	}
+
+	if _, err := store.Get(ctx, "blob"); err == nil {
+		t.Fatal("Expected getting deleted blob to fail")
+	}
+}
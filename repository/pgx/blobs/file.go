@@ -0,0 +1,60 @@
+package blobs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileStore keeps each blob as a file on a directory.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+// Put writes to a temporary file renamed over the final one, so that a blob is
+// never read half written.
+func (store *FileStore) Put(_ context.Context, name string, data []byte) error {
+	file, err := os.CreateTemp(store.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), store.path(name))
+}
+
+func (store *FileStore) Get(_ context.Context, name string) ([]byte, error) {
+	return os.ReadFile(store.path(name))
+}
+
+// Delete succeeds for blobs already deleted, so that cleanups can be retried.
+func (store *FileStore) Delete(_ context.Context, name string) error {
+	if err := os.Remove(store.path(name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (store *FileStore) path(name string) string {
+	return filepath.Join(store.dir, filepath.Base(name))
+}
@@ -0,0 +1,49 @@
+package blobs
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// S3Store keeps each blob as an object on a bucket of any S3 compatible
+// storage, such as AWS S3 or MinIO.
+type S3Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func NewS3Store(client *minio.Client, bucket string, prefix string) *S3Store {
+	return &S3Store{client: client, bucket: bucket, prefix: prefix}
+}
+
+func (store *S3Store) Put(ctx context.Context, name string, data []byte) error {
+	_, err := store.client.PutObject(
+		ctx,
+		store.bucket,
+		store.prefix+name,
+		bytes.NewReader(data),
+		int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/octet-stream"},
+	)
+
+	return err
+}
+
+func (store *S3Store) Get(ctx context.Context, name string) ([]byte, error) {
+	object, err := store.client.GetObject(ctx, store.bucket, store.prefix+name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	return io.ReadAll(object)
+}
+
+// Delete succeeds for blobs already deleted, as S3 itself does.
+func (store *S3Store) Delete(ctx context.Context, name string) error {
+	return store.client.RemoveObject(ctx, store.bucket, store.prefix+name, minio.RemoveObjectOptions{})
+}
@@ -0,0 +1,164 @@
+package pgx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/internal/testutil"
+	"github.com/dalthon/ana/repository/pgx/blobs"
+
+	"testing"
+)
+
+var _ BlobStore = (*blobs.FileStore)(nil)
+var _ BlobStore = (*blobs.S3Store)(nil)
+
+type memoryBlobStore struct {
+	mutex sync.Mutex
+	blobs map[string][]byte
+}
+
+func newMemoryBlobStore() *memoryBlobStore {
+	return &memoryBlobStore{blobs: map[string][]byte{}}
+}
+
+func (store *memoryBlobStore) Put(_ context.Context, name string, data []byte) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.blobs[name] = data
+	return nil
+}
+
+type failingBlobStore struct {
+	memoryBlobStore
+}
+
+func (store *failingBlobStore) Put(context.Context, string, []byte) error {
+	return errors.New("Could not put blob")
+}
+
+func (store *memoryBlobStore) Get(_ context.Context, name string) ([]byte, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	data, ok := store.blobs[name]
+	if !ok {
+		return nil, errors.New("Blob not found")
+	}
+
+	return data, nil
+}
+
+func (store *memoryBlobStore) Delete(_ context.Context, name string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.blobs, name)
+	return nil
+}
+
+func (store *memoryBlobStore) len() int {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return len(store.blobs)
+}
+
+func TestBlobName(t *testing.T) {
+	name := blobName("target", "key/with/slashes", 2)
+
+	testutil.AssertEqual(t, true, strings.HasSuffix(name, "-2"))
+	testutil.AssertEqual(t, false, strings.Contains(name, "/"))
+	testutil.AssertEqual(t, name, blobName("target", "key/with/slashes", 2))
+
+	if name == blobName("target", "key/with/slashes", 3) || name == blobName("another", "key/with/slashes", 2) {
+		t.Fatalf("Expected blob names to be distinct, but got %s", name)
+	}
+}
+
+func TestPgxRepositoryBlobStore(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	store := newMemoryBlobStore()
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, WithBlobStore(store, 64))
+
+	small := testutil.NewMockedOperation[pgxCtx]("small", "target", "payload", "result", true)
+	trackedOperation := repo.FetchOrStart(small)
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	testutil.AssertErrorNil(t, repo.NewSession(small).Context.Success(trackedOperation))
+	testutil.AssertEqual(t, 0, store.len())
+
+	largeResult := strings.Repeat("large ", 100)
+	large := testutil.NewMockedOperation[pgxCtx]("large", "target", "payload", largeResult, true)
+	trackedOperation = repo.FetchOrStart(large)
+	trackedOperation.Result = &testutil.DebugResult{Value: largeResult}
+	testutil.AssertErrorNil(t, repo.NewSession(large).Context.Success(trackedOperation))
+	testutil.AssertEqual(t, 1, store.len())
+
+	var storedResult []byte
+	var resultRef string
+	pool.QueryRow(
+		context.Background(),
+		"SELECT result, result_ref FROM ana.tracked_operations WHERE key = 'large';",
+	).Scan(&storedResult, &resultRef)
+	testutil.AssertEqual(t, 0, len(storedResult))
+	testutil.AssertEqual(t, blobName("target", "large", 1), resultRef)
+
+	testutil.AssertEqual(t, largeResult, repo.FetchOrStart(large).Result.Value)
+	testutil.AssertEqual(t, "result", repo.FetchOrStart(small).Result.Value)
+	testutil.AssertEqual(t, largeResult, repo.Lookup("large", "target").Result.Value)
+
+	pool.Exec(context.Background(), "UPDATE ana.tracked_operations SET expiration = NOW() - '1 minute'::interval;")
+	testutil.AssertEqual(t, int64(2), repo.DeleteExpired(a.Finished, 10))
+	testutil.AssertEqual(t, 0, store.len())
+}
+
+func TestPgxContextSupersededBlob(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	store := newMemoryBlobStore()
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, WithBlobStore(store, 0))
+	operation := testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true)
+
+	trackedOperation := repo.FetchOrStart(operation)
+	staleSession := repo.NewSession(operation)
+	session := repo.NewSession(operation)
+
+	staleOperation := *trackedOperation
+	staleOperation.Result = &testutil.DebugResult{Value: "stale result"}
+	err := staleSession.Context.Success(&staleOperation)
+	if _, ok := err.(*a.SupersededError); !ok {
+		t.Fatalf("Expected to have superseded error, but got \"%v\"", err)
+	}
+	testutil.AssertEqual(t, 0, store.len())
+
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	testutil.AssertErrorNil(t, session.Context.Success(trackedOperation))
+	testutil.AssertEqual(t, 1, store.len())
+	testutil.AssertEqual(t, "result", repo.Lookup("key", "target").Result.Value)
+}
+
+func TestPgxContextFailedBlob(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, WithBlobStore(&failingBlobStore{}, 0))
+	operation := testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true)
+
+	trackedOperation := repo.FetchOrStart(operation)
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	err := repo.NewSession(operation).Context.Success(trackedOperation)
+	if err == nil || err.Error() != "Could not put blob" {
+		t.Fatalf("Expected to have blob error, but got \"%v\"", err)
+	}
+
+	failedOperation := repo.Lookup("key", "target")
+	testutil.AssertEqual(t, a.Failed, failedOperation.Status)
+	testutil.AssertEqual(t, "Could not put blob", failedOperation.Err.Error())
+}
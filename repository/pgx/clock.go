@@ -0,0 +1,61 @@
+package pgx
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const DatabaseClockRefresh time.Duration = time.Minute
+
+var databaseNowQuery string = `
+  SELECT clock_timestamp();
+`
+
+// DatabaseClock follows database time, so that decisions taken in Go agree with
+// the ones taken by queries relying on NOW(). It measures its skew against
+// local clock once every refresh interval and corrects local time with it.
+type DatabaseClock struct {
+	pool     *pgxpool.Pool
+	refresh  time.Duration
+	mutex    sync.Mutex
+	offset   time.Duration
+	syncedAt time.Time
+}
+
+func NewDatabaseClock(pool *pgxpool.Pool, refresh time.Duration) *DatabaseClock {
+	return &DatabaseClock{pool: pool, refresh: refresh}
+}
+
+func (clock *DatabaseClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	now := time.Now()
+	if clock.syncedAt.IsZero() || now.Sub(clock.syncedAt) >= clock.refresh {
+		clock.sync()
+		now = time.Now()
+	}
+
+	return now.Add(clock.offset)
+}
+
+func (clock *DatabaseClock) sync() {
+	var databaseNow time.Time
+
+	before := time.Now()
+	if err := clock.pool.QueryRow(context.Background(), databaseNowQuery).Scan(&databaseNow); err != nil {
+		panic(err)
+	}
+	after := time.Now()
+
+	clock.offset = skew(before, after, databaseNow)
+	clock.syncedAt = after
+}
+
+// Database time is assumed to be read halfway through the round trip.
+func skew(before time.Time, after time.Time, databaseNow time.Time) time.Duration {
+	return databaseNow.Sub(before.Add(after.Sub(before) / 2))
+}
@@ -0,0 +1,59 @@
+package pgx
+
+import (
+	"context"
+	"time"
+
+	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/internal/testutil"
+
+	"testing"
+)
+
+func TestSkew(t *testing.T) {
+	before := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	after := before.Add(20 * time.Millisecond)
+
+	testutil.AssertEqual(t, 5*time.Second, skew(before, after, before.Add(5*time.Second+10*time.Millisecond)))
+	testutil.AssertEqual(t, -5*time.Second, skew(before, after, before.Add(-5*time.Second+10*time.Millisecond)))
+}
+
+func TestDatabaseClock(t *testing.T) {
+	pool := newPool()
+	clock := NewDatabaseClock(pool, DatabaseClockRefresh)
+
+	var databaseNow time.Time
+	if err := pool.QueryRow(context.Background(), databaseNowQuery).Scan(&databaseNow); err != nil {
+		t.Fatal(err)
+	}
+
+	now := clock.Now()
+	if now.Sub(databaseNow).Abs() > time.Second {
+		t.Fatalf("Expected database clock %v to be close to %v", now, databaseNow)
+	}
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, WithDatabaseClock())
+	if _, ok := repo.Clock().(*DatabaseClock); !ok {
+		t.Fatalf("Expected repository to use database clock, but got %T", repo.Clock())
+	}
+}
+
+func TestPgxContextClock(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	clock := a.NewFakeClock(time.Now().Add(time.Hour))
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, WithClock(clock))
+	operation := testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true)
+
+	trackedOperation := repo.FetchOrStart(operation)
+	testutil.AssertEqual(t, trackedOperation.Status, a.Ready)
+
+	trackedOperation.Expiration = time.Now().Add(10 * time.Second)
+	session := repo.NewSession(operation)
+	session.Context.Success(trackedOperation)
+
+	refreshedOperation := repo.FetchOrStart(operation)
+	testutil.AssertEqual(t, refreshedOperation.Status, a.Failed)
+	testutil.AssertEqual(t, refreshedOperation.Err.Error(), "Operation expired")
+}
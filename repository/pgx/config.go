@@ -0,0 +1,225 @@
+package pgx
+
+import (
+	"strings"
+	"text/template"
+	"time"
+
+	a "github.com/dalthon/ana"
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultSchema string = "ana"
+	defaultTable  string = "tracked_operations"
+)
+
+const DefaultSerializationRetries int = 3
+
+type Option func(*config)
+
+func WithSchema(schema string) Option {
+	return func(config *config) {
+		config.schema = schema
+	}
+}
+
+func WithTable(table string) Option {
+	return func(config *config) {
+		config.table = table
+	}
+}
+
+// WithPartitioning lays tracked operations out on range partitions of given
+// interval by expiration, so that expired ones are cheaply dropped by
+// MaintainPartitions instead of deleted. It must be set when the table is first
+// created by Migrate, since an existing table is never converted.
+func WithPartitioning(interval time.Duration) Option {
+	return func(config *config) {
+		config.partitionInterval = interval
+	}
+}
+
+// WithClock sets the clock used by repository, and by managers built on top of
+// it, on expiration decisions.
+func WithClock(clock a.Clock) Option {
+	return func(config *config) {
+		config.clock = clock
+	}
+}
+
+// WithDatabaseClock makes repository follow database time through a
+// DatabaseClock, so that it agrees with decisions taken by queries.
+func WithDatabaseClock() Option {
+	return func(config *config) {
+		config.databaseClock = true
+	}
+}
+
+// WithBlobStore makes results whose encoding is larger than threshold bytes be
+// written to given store, keeping only their names on tracked operations table.
+// Blobs are deleted along with their operations by DeleteExpired and
+// MaintainPartitions.
+func WithBlobStore(store BlobStore, threshold int) Option {
+	return func(config *config) {
+		config.blobs = store
+		config.blobThreshold = threshold
+	}
+}
+
+// WithErrorRegistry sets the registry rebuilding typed errors of failed
+// operations, which is ana.DefaultErrorRegistry by default.
+func WithErrorRegistry(registry *a.ErrorRegistry) Option {
+	return func(config *config) {
+		config.errors = registry
+	}
+}
+
+// WithTxOptions sets options of transactions operations run within, unless
+// operations implement TxOptionsOperation. Read only access mode applies only
+// to operation work, since its outcome must still be recorded.
+func WithTxOptions(options pgx.TxOptions) Option {
+	return func(config *config) {
+		config.txOptions = options
+	}
+}
+
+// WithSerializationRetries bounds how many times a session is run again from
+// scratch when it fails with a serialization failure, which is
+// DefaultSerializationRetries by default.
+func WithSerializationRetries(retries int) Option {
+	return func(config *config) {
+		config.serializationRetries = retries
+	}
+}
+
+// WithRelayErrorHandler sets what OutboxRelay.Run does with draining errors,
+// which it otherwise ignores, since it keeps draining until its context is done.
+func WithRelayErrorHandler(handler func(error)) Option {
+	return func(config *config) {
+		config.relayErrors = handler
+	}
+}
+
+type config struct {
+	schema            string
+	table             string
+	partitionInterval time.Duration
+	clock             a.Clock
+	databaseClock     bool
+	blobs             BlobStore
+	blobThreshold     int
+	payloadEncoder    payloadEncoder
+	hashedPayload     bool
+	errors            *a.ErrorRegistry
+	relayErrors       func(error)
+
+	txOptions            pgx.TxOptions
+	serializationRetries int
+}
+
+func newConfig(options []Option) *config {
+	config := &config{
+		schema: defaultSchema,
+		table:  defaultTable,
+		clock:  a.SystemClock,
+		errors: a.DefaultErrorRegistry,
+
+		serializationRetries: DefaultSerializationRetries,
+	}
+
+	for _, option := range options {
+		option(config)
+	}
+
+	return config
+}
+
+func (config *config) identifier(name string) string {
+	return pgx.Identifier{config.schema, name}.Sanitize()
+}
+
+// Objects owned by a table other than the default one are prefixed by its
+// name, so that many tables can share the same schema.
+func (config *config) tableObject(name string) string {
+	if config.table == defaultTable {
+		return config.identifier(name)
+	}
+
+	return config.identifier(config.table + "_" + name)
+}
+
+func (config *config) partitioned() bool {
+	return config.partitionInterval != time.Duration(0)
+}
+
+func (config *config) names() map[string]any {
+	return map[string]any{
+		"Schema":           pgx.Identifier{config.schema}.Sanitize(),
+		"Table":            config.identifier(config.table),
+		"Migrations":       config.identifier(config.table + "_migrations"),
+		"StatusType":       config.identifier("operation_status"),
+		"FetchOrStart":     config.tableObject("fetch_or_start"),
+		"Outbox":           config.tableObject("outbox"),
+		"DefaultPartition": config.identifier(config.table + "_default"),
+		"TargetKeyIndex":   pgx.Identifier{config.table + "_target_key_idx"}.Sanitize(),
+		"Partitioned":      config.partitioned(),
+	}
+}
+
+func (config *config) lock() string {
+	return config.schema + "." + config.table
+}
+
+func (config *config) render(text string) string {
+	return config.renderWith(text, config.names())
+}
+
+func (config *config) renderWith(text string, names map[string]any) string {
+	var builder strings.Builder
+
+	tmpl := template.Must(template.New("").Parse(text))
+	if err := tmpl.Execute(&builder, names); err != nil {
+		panic(err)
+	}
+
+	return builder.String()
+}
+
+type queries struct {
+	fetchOrStart             string
+	fetchOrStartBatch        string
+	lookup                   string
+	claimTrackedOperation    string
+	lockTrackOperation       string
+	failTimedOutStillRunning string
+	failExpiredStillRunning  string
+	deleteExpired            string
+	finishTrackedOperation   string
+	failTrackedOperation     string
+	extendTrackedOperation   string
+	enqueueOutbox            string
+	pendingOutbox            string
+	deleteOutbox             string
+}
+
+var defaultQueries *queries = newQueries(newConfig(nil))
+
+func newQueries(config *config) *queries {
+	return &queries{
+		fetchOrStart:             config.render(fetchOrStartQuery),
+		fetchOrStartBatch:        config.render(fetchOrStartBatchQuery),
+		lookup:                   config.render(lookupQuery),
+		claimTrackedOperation:    config.render(claimTrackedOperationQuery),
+		lockTrackOperation:       config.render(lockTrackOperationQuery),
+		failTimedOutStillRunning: config.render(failTimedOutStillRunningQuery),
+		failExpiredStillRunning:  config.render(failExpiredStillRunningQuery),
+		deleteExpired:            config.render(deleteExpiredQuery),
+		finishTrackedOperation:   config.render(finishTrackedOperationQuery),
+		failTrackedOperation:     config.render(failTrackedOperationQuery),
+		extendTrackedOperation:   config.render(extendTrackedOperationQuery),
+		enqueueOutbox:            config.render(enqueueOutboxQuery),
+		pendingOutbox:            config.render(pendingOutboxQuery),
+		deleteOutbox:             config.render(deleteOutboxQuery),
+	}
+}
@@ -0,0 +1,52 @@
+package pgx
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dalthon/ana/internal/testutil"
+
+	"testing"
+)
+
+func TestDefaultQueries(t *testing.T) {
+	testutil.AssertContains(t, defaultQueries.fetchOrStart, `FROM "ana"."fetch_or_start"(`)
+	testutil.AssertContains(t, defaultQueries.lockTrackOperation, `FROM "ana"."tracked_operations"`)
+	testutil.AssertContains(t, defaultQueries.finishTrackedOperation, `UPDATE "ana"."tracked_operations"`)
+}
+
+func TestCustomQueries(t *testing.T) {
+	queries := newQueries(newConfig([]Option{WithSchema("billing"), WithTable("short_lived")}))
+
+	testutil.AssertContains(t, queries.fetchOrStart, `FROM "billing"."short_lived_fetch_or_start"(`)
+	testutil.AssertContains(t, queries.deleteExpired, `DELETE FROM "billing"."short_lived"`)
+	testutil.AssertContains(t, queries.failTrackedOperation, `UPDATE "billing"."short_lived"`)
+}
+
+func TestCustomMigrations(t *testing.T) {
+	migrations, err := loadMigrations(newConfig([]Option{WithSchema("billing"), WithTable("short_lived")}))
+	testutil.AssertErrorNil(t, err)
+
+	script := migrations[0].script
+	testutil.AssertContains(t, script, `CREATE SCHEMA IF NOT EXISTS "billing";`)
+	testutil.AssertContains(t, script, `CREATE TABLE IF NOT EXISTS "billing"."short_lived" (`)
+	testutil.AssertContains(t, script, `CREATE OR REPLACE FUNCTION "billing"."short_lived_fetch_or_start"(`)
+
+	if strings.Contains(script, "ana") {
+		t.Fatalf("Expected migrations to not reference ana schema, but got:\n%s", script)
+	}
+}
+
+func TestPartitionedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(newConfig([]Option{WithPartitioning(time.Hour)}))
+	testutil.AssertErrorNil(t, err)
+
+	script := migrations[0].script
+	testutil.AssertContains(t, script, `) PARTITION BY RANGE (expiration);`)
+	testutil.AssertContains(t, script, `CREATE TABLE IF NOT EXISTS "ana"."tracked_operations_default" PARTITION OF "ana"."tracked_operations" DEFAULT;`)
+	testutil.AssertContains(t, script, `PERFORM pg_advisory_xact_lock(hashtext(_target), hashtext(_key));`)
+
+	if strings.Contains(script, "PRIMARY KEY") {
+		t.Fatalf("Expected partitioned migrations to not have a primary key, but got:\n%s", script)
+	}
+}
@@ -3,101 +3,347 @@ package pgx
 import (
 	"context"
 	"errors"
+	"sync"
 	"time"
 
 	a "github.com/dalthon/ana"
 	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const serializationFailure string = "40001"
+
+// StatementTimeoutSlack is how much longer than operation timeout statements may
+// run before database cancels them, backing up contexts that are not canceled
+// when operations time out, such as those within caller transactions.
+const StatementTimeoutSlack time.Duration = time.Second
+
 var finishTrackedOperationQuery string = `
-  UPDATE ana.tracked_operations
+  UPDATE {{.Table}}
   SET
     payload       = @payload,
     result        = @result,
+    result_ref    = @result_ref,
     finished_at   = NOW(),
     status        = 'finished',
-    error_message = NULL
+    error_message = NULL,
+    error         = NULL
   WHERE
-    key = @key AND target = @target;
+    key = @key AND target = @target AND attempt = @attempt;
 `
 
 var failTrackedOperationQuery string = `
-  UPDATE ana.tracked_operations
+  UPDATE {{.Table}}
   SET
     payload       = @payload,
     result        = NULL,
+    result_ref    = NULL,
     finished_at   = NOW(),
     status        = 'failed',
     timeout       = NOW(),
     error_message = @error_message,
+    error         = @error,
     error_count   = error_count + 1
   WHERE
-    key = @key AND target = @target;
+    key = @key AND target = @target AND attempt = @attempt;
+`
+
+var restoreStatementTimeoutQuery string = `
+  SELECT set_config('statement_timeout', @statement_timeout, true);
+`
+
+var extendTrackedOperationQuery string = `
+  UPDATE {{.Table}}
+  SET
+    timeout = NOW() + @timeout
+  WHERE
+    key = @key AND target = @target AND attempt = @attempt AND status = 'running';
 `
 
 type PgxContext[P any, R any] struct {
-	outerTx pgx.Tx
-	Tx      pgx.Tx
-	Context context.Context
+	outerTx          pgx.Tx
+	queries          *queries
+	pool             *pgxpool.Pool
+	extendable       bool
+	clock            a.Clock
+	blobs            BlobStore
+	blobThreshold    int
+	payloadEncoder   payloadEncoder
+	readOnly         bool
+	retries          int
+	key              string
+	target           string
+	statementTimeout *string
+	lease            *leaseContext
+	base             context.Context
+	cancel           context.CancelFunc
+	Tx               pgx.Tx
+	Context          context.Context
+	Attempt          int64
 }
 
 func NewPgxContext[P any, R any](outerTx pgx.Tx, tx pgx.Tx, context context.Context) *PgxContext[P, R] {
-	return &PgxContext[P, R]{outerTx: outerTx, Tx: tx, Context: context}
+	return &PgxContext[P, R]{
+		outerTx: outerTx,
+		queries: defaultQueries,
+		clock:   a.SystemClock,
+		base:    context,
+		cancel:  func() {},
+		Tx:      tx,
+		Context: context,
+	}
+}
+
+// Extend sets operation timeout to given duration from now, pushing context
+// deadline along. It runs outside operation transaction, so that it is seen by
+// everyone right away.
+func (ctx *PgxContext[P, R]) Extend(timeout time.Duration) error {
+	if ctx.pool == nil || !ctx.extendable {
+		return errors.New("Context can not be extended")
+	}
+
+	_, err := ctx.pool.Exec(
+		ctx.base,
+		ctx.queries.extendTrackedOperation,
+		pgx.NamedArgs{
+			"key":     ctx.key,
+			"target":  ctx.target,
+			"attempt": ctx.Attempt,
+			"timeout": timeout,
+		},
+	)
+
+	if err == nil && ctx.lease != nil {
+		ctx.lease.extend(timeout)
+	}
+
+	return err
 }
 
-func (ctx *PgxContext[P, R]) Success(operation *a.TrackedOperation[P, R]) {
-	if !operation.Expiration.IsZero() && time.Now().After(operation.Expiration) {
+func (ctx *PgxContext[P, R]) Success(operation *a.TrackedOperation[P, R]) error {
+	ctx.cancel()
+
+	if !operation.Expiration.IsZero() && ctx.clock.Now().After(operation.Expiration) {
 		operation.Err = errors.New("Operation expired")
-		ctx.Fail(operation)
-		return
+		return ctx.Fail(operation)
 	}
 
-	if commitErr := ctx.Tx.Commit(ctx.Context); commitErr != nil {
-		panic(commitErr)
+	result := serialize(operation.Result)
+
+	var resultRef *string
+	if ctx.blobs != nil && len(result) > ctx.blobThreshold {
+		// Operations whose results could not be stored are failed, instead of
+		// being left running until they time out.
+		name := blobName(operation.Target, operation.Key, ctx.Attempt)
+		if err := ctx.blobs.Put(ctx.base, name, result); err != nil {
+			operation.Err = err
+			if failErr := ctx.Fail(operation); failErr != nil {
+				return failErr
+			}
+
+			return err
+		}
+
+		result = nil
+		resultRef = &name
+	}
+
+	if err := ctx.saveWork(); err != nil {
+		ctx.outerTx.Rollback(ctx.base)
+		return ctx.discardBlob(resultRef, err)
 	}
 
-	_, err := ctx.outerTx.Exec(
-		ctx.Context,
-		finishTrackedOperationQuery,
+	err := ctx.finish(
+		operation,
+		ctx.queries.finishTrackedOperation,
 		pgx.NamedArgs{
-			"key":     operation.Key,
-			"target":  operation.Target,
-			"payload": serialize(operation.Payload),
-			"result":  serialize(operation.Result),
+			"key":        operation.Key,
+			"target":     operation.Target,
+			"attempt":    ctx.Attempt,
+			"payload":    encodePayload(ctx.payloadEncoder, operation.Payload),
+			"result":     result,
+			"result_ref": resultRef,
 		},
 	)
 
+	return ctx.discardBlob(resultRef, err)
+}
+
+func (ctx *PgxContext[P, R]) Fail(operation *a.TrackedOperation[P, R]) error {
+	ctx.cancel()
+
+	encodedErr, err := encodeError(operation.Err)
 	if err != nil {
-		ctx.outerTx.Rollback(ctx.Context)
-		panic(err)
+		ctx.outerTx.Rollback(ctx.base)
+		return err
 	}
 
-	if commitErr := ctx.outerTx.Commit(ctx.Context); commitErr != nil {
-		panic(commitErr)
+	args := pgx.NamedArgs{
+		"key":           operation.Key,
+		"target":        operation.Target,
+		"attempt":       ctx.Attempt,
+		"payload":       encodePayload(ctx.payloadEncoder, operation.Payload),
+		"error_message": operation.Err.Error(),
+		"error":         encodedErr,
 	}
+
+	// Connections are closed when contexts are canceled amid statements, taking
+	// operation work along, so failures are then recorded on a new one.
+	if ctx.pool != nil && ctx.outerTx.Conn().IsClosed() {
+		return ctx.finishDetached(operation, ctx.queries.failTrackedOperation, args)
+	}
+
+	if err := ctx.Tx.Rollback(ctx.base); err != nil {
+		ctx.outerTx.Rollback(ctx.base)
+		return err
+	}
+
+	return ctx.finish(operation, ctx.queries.failTrackedOperation, args)
 }
 
-func (ctx *PgxContext[P, R]) Fail(operation *a.TrackedOperation[P, R]) {
-	if commitErr := ctx.Tx.Rollback(ctx.Context); commitErr != nil {
-		panic(commitErr)
+// ShouldRetry tells to run session again on serialization failures, as long as
+// it was not retried more than repository allows.
+func (ctx *PgxContext[P, R]) ShouldRetry(err error, retries int) bool {
+	var pgErr *pgconn.PgError
+	return retries < ctx.retries && errors.As(err, &pgErr) && pgErr.Code == serializationFailure
+}
+
+// Abort rolls everything back, leaving operation running until it is claimed
+// again by a new session.
+func (ctx *PgxContext[P, R]) Abort() error {
+	ctx.cancel()
+
+	if err := ctx.outerTx.Rollback(ctx.base); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		return err
 	}
 
-	_, err := ctx.outerTx.Exec(
-		ctx.Context,
-		failTrackedOperationQuery,
-		pgx.NamedArgs{
-			"key":           operation.Key,
-			"target":        operation.Target,
-			"payload":       serialize(operation.Payload),
-			"error_message": operation.Err.Error(),
-		},
-	)
+	return nil
+}
+
+// CommitWork saves operation work on outer transaction and commits it.
+func (ctx *PgxContext[P, R]) CommitWork() error {
+	ctx.cancel()
+
+	if err := ctx.saveWork(); err != nil {
+		ctx.outerTx.Rollback(ctx.base)
+		return err
+	}
 
+	return ctx.outerTx.Commit(ctx.base)
+}
+
+// Read only work has nothing to keep, and rolling it back also restores write
+// access needed to record its outcome. Otherwise statement timeout must be
+// restored, so that it does not outlive operation within caller transactions.
+func (ctx *PgxContext[P, R]) saveWork() error {
+	if ctx.readOnly {
+		return ctx.Tx.Rollback(ctx.base)
+	}
+
+	if ctx.statementTimeout != nil {
+		_, err := ctx.Tx.Exec(ctx.base, restoreStatementTimeoutQuery, pgx.NamedArgs{
+			"statement_timeout": *ctx.statementTimeout,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return ctx.Tx.Commit(ctx.base)
+}
+
+// finish commits outer transaction, unless no row matched operation attempt.
+func (ctx *PgxContext[P, R]) finish(operation *a.TrackedOperation[P, R], query string, args pgx.NamedArgs) error {
+	info, err := ctx.outerTx.Exec(ctx.base, query, args)
 	if err != nil {
-		panic(err)
+		ctx.outerTx.Rollback(ctx.base)
+		return err
+	}
+
+	if info.RowsAffected() == 0 {
+		ctx.outerTx.Rollback(ctx.base)
+		return a.NewSupersededError(operation.Target, operation.Key)
+	}
+
+	return ctx.outerTx.Commit(ctx.base)
+}
+
+func (ctx *PgxContext[P, R]) finishDetached(operation *a.TrackedOperation[P, R], query string, args pgx.NamedArgs) error {
+	ctx.outerTx.Rollback(ctx.base)
+
+	info, err := ctx.pool.Exec(ctx.base, query, args)
+	if err != nil {
+		return err
+	}
+
+	if info.RowsAffected() == 0 {
+		return a.NewSupersededError(operation.Target, operation.Key)
+	}
+
+	return nil
+}
+
+// Blobs written by attempts that could not finish are never referenced, so
+// they are deleted right away.
+func (ctx *PgxContext[P, R]) discardBlob(name *string, err error) error {
+	if err == nil || name == nil {
+		return err
 	}
 
-	if commitErr := ctx.outerTx.Commit(ctx.Context); commitErr != nil {
-		panic(commitErr)
+	if deleteErr := ctx.blobs.Delete(ctx.base, *name); deleteErr != nil {
+		return errors.Join(err, deleteErr)
 	}
+
+	return err
+}
+
+// leaseContext is canceled once its deadline passes, like contexts made by
+// context.WithDeadline, except that its deadline may be pushed forward while it
+// has not passed yet.
+type leaseContext struct {
+	context.Context
+	cancel   context.CancelCauseFunc
+	mutex    sync.Mutex
+	deadline time.Time
+	timer    *time.Timer
+}
+
+func newLeaseContext(parent context.Context, timeout time.Duration) *leaseContext {
+	ctx := &leaseContext{deadline: time.Now().Add(timeout)}
+	ctx.Context, ctx.cancel = context.WithCancelCause(parent)
+	ctx.timer = time.AfterFunc(timeout, func() { ctx.cancel(context.DeadlineExceeded) })
+
+	return ctx
+}
+
+func (ctx *leaseContext) Deadline() (time.Time, bool) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+
+	return ctx.deadline, true
+}
+
+func (ctx *leaseContext) Err() error {
+	if err := ctx.Context.Err(); err != nil {
+		return context.Cause(ctx.Context)
+	}
+
+	return nil
+}
+
+func (ctx *leaseContext) extend(timeout time.Duration) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+
+	if ctx.timer.Stop() {
+		ctx.deadline = time.Now().Add(timeout)
+		ctx.timer.Reset(timeout)
+	}
+}
+
+func (ctx *leaseContext) stop() {
+	ctx.timer.Stop()
+	ctx.cancel(context.Canceled)
 }
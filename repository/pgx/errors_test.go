@@ -0,0 +1,67 @@
+package pgx
+
+import (
+	"errors"
+	"fmt"
+
+	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/internal/testutil"
+
+	"testing"
+)
+
+type rejectedError struct {
+	Reason string `json:"reason"`
+}
+
+func (err *rejectedError) Error() string {
+	return fmt.Sprintf("Rejected: %s", err.Reason)
+}
+
+func (err *rejectedError) ErrorCode() string {
+	return "rejected"
+}
+
+func (err *rejectedError) ErrorDetails() any {
+	return err
+}
+
+func TestErrorCodec(t *testing.T) {
+	registry := a.NewErrorRegistry()
+	a.RegisterError[rejectedError](registry, "rejected")
+
+	encoded, err := encodeError(&rejectedError{"insufficient funds"})
+	testutil.AssertErrorNil(t, err)
+
+	var rejected *rejectedError
+	decoded := decodeError(registry, encoded)
+	if !errors.As(decoded, &rejected) {
+		t.Fatalf("Expected to rebuild rejected error, but got \"%v\"", decoded)
+	}
+	testutil.AssertEqual(t, "insufficient funds", rejected.Reason)
+}
+
+func TestPgxContextFailTypedError(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	registry := a.NewErrorRegistry()
+	a.RegisterError[rejectedError](registry, "rejected")
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, WithErrorRegistry(registry))
+	operation := testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true)
+
+	trackedOperation := repo.FetchOrStart(operation)
+	testutil.AssertEqual(t, trackedOperation.Status, a.Ready)
+
+	trackedOperation.Err = &rejectedError{"insufficient funds"}
+	session := repo.NewSession(operation)
+	testutil.AssertErrorNil(t, session.Context.Fail(trackedOperation))
+
+	var rejected *rejectedError
+	refreshedOperation := repo.Lookup("key", "target")
+	if !errors.As(refreshedOperation.Err, &rejected) {
+		t.Fatalf("Expected to rebuild rejected error, but got \"%v\"", refreshedOperation.Err)
+	}
+	testutil.AssertEqual(t, "insufficient funds", rejected.Reason)
+}
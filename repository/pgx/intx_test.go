@@ -0,0 +1,46 @@
+package pgx
+
+import (
+	"context"
+
+	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/internal/testutil"
+
+	"testing"
+)
+
+func TestPgxRepositoryInTx(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	ctx := context.Background()
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
+
+	for _, commit := range []bool{false, true} {
+		tx, err := pool.Begin(ctx)
+		testutil.AssertErrorNil(t, err)
+
+		manager := a.New[testutil.DebugPayload, testutil.DebugResult, pgxCtx](repo.InTx(tx))
+		result, err := manager.Call(testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true))
+		testutil.AssertErrorNil(t, err)
+		testutil.AssertEqual(t, "result", result.Value)
+
+		_, err = manager.Call(testutil.NewMockedOperation[pgxCtx]("failed", "target", "payload", "Something went wrong", false))
+		testutil.AssertEqual(t, "Something went wrong", err.Error())
+
+		testutil.AssertNil(t, repo.Lookup("key", "target"))
+		testutil.AssertEqual(t, a.Finished, repo.InTx(tx).Lookup("key", "target").Status)
+		testutil.AssertEqual(t, a.Failed, repo.InTx(tx).Lookup("failed", "target").Status)
+
+		if !commit {
+			testutil.AssertErrorNil(t, tx.Rollback(ctx))
+			testutil.AssertNil(t, repo.Lookup("key", "target"))
+			testutil.AssertNil(t, repo.Lookup("failed", "target"))
+			continue
+		}
+
+		testutil.AssertErrorNil(t, tx.Commit(ctx))
+		testutil.AssertEqual(t, a.Finished, repo.Lookup("key", "target").Status)
+		testutil.AssertEqual(t, a.Failed, repo.Lookup("failed", "target").Status)
+	}
+}
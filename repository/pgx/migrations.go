@@ -0,0 +1,151 @@
+package pgx
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var lockMigrationsQuery string = `
+  SELECT pg_advisory_xact_lock(hashtext(@lock));
+`
+
+var createMigrationsTableQuery string = `
+  CREATE SCHEMA IF NOT EXISTS {{.Schema}};
+
+  CREATE TABLE IF NOT EXISTS {{.Migrations}} (
+    version    bigint      NOT NULL,
+    name       varchar     NOT NULL,
+    applied_at timestamptz NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(version)
+  );
+`
+
+var appliedMigrationsQuery string = `
+  SELECT version FROM {{.Migrations}};
+`
+
+var insertMigrationQuery string = `
+  INSERT INTO {{.Migrations}} (version, name)
+  VALUES (@version, @name);
+`
+
+type migration struct {
+	version int64
+	name    string
+	script  string
+}
+
+// Migrate applies every embedded migration not yet recorded on database.
+//
+// Migrations are forward-only and run inside a single transaction holding an
+// advisory lock, so it is safe to call it from many replicas at once. It must
+// receive the same options given to NewPgxRepository.
+func Migrate(ctx context.Context, pool *pgxpool.Pool, options ...Option) error {
+	config := newConfig(options)
+
+	migrations, err := loadMigrations(config)
+	if err != nil {
+		return err
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, lockMigrationsQuery, pgx.NamedArgs{"lock": config.lock()}); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, config.render(createMigrationsTableQuery)); err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(ctx, tx, config)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if applied[migration.version] {
+			continue
+		}
+
+		if _, err := tx.Exec(ctx, migration.script); err != nil {
+			return fmt.Errorf("migration %s failed: %w", migration.name, err)
+		}
+
+		_, err := tx.Exec(ctx, config.render(insertMigrationQuery), pgx.NamedArgs{
+			"version": migration.version,
+			"name":    migration.name,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func appliedMigrations(ctx context.Context, tx pgx.Tx, config *config) (map[int64]bool, error) {
+	rows, err := tx.Query(ctx, config.render(appliedMigrationsQuery))
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]bool, len(versions))
+	for _, version := range versions {
+		applied[version] = true
+	}
+
+	return applied, nil
+}
+
+func loadMigrations(config *config) ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+
+		version, err := strconv.ParseInt(strings.SplitN(name, "_", 2)[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration name %s", entry.Name())
+		}
+
+		script, err := fs.ReadFile(migrationFiles, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, migration{version, name, config.render(string(script))})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
@@ -1,41 +1,55 @@
-CREATE SCHEMA IF NOT EXISTS ana;
+CREATE SCHEMA IF NOT EXISTS {{.Schema}};
 
-CREATE TYPE ana.operation_status AS ENUM(
-  'ready', 'running', 'finished', 'failed'
-);
+DO $$
+BEGIN
+  CREATE TYPE {{.StatusType}} AS ENUM(
+    'ready', 'running', 'finished', 'failed'
+  );
+EXCEPTION
+  WHEN duplicate_object THEN NULL;
+END;
+$$;
 
-CREATE TABLE IF NOT EXISTS ana.tracked_operations (
+CREATE TABLE IF NOT EXISTS {{.Table}} (
   reference_time timestamptz          NOT NULL,
   started_at     timestamptz          NOT NULL,
   finished_at    timestamptz,
   timeout        timestamptz,
   expiration     timestamptz,
   error_count    integer              NOT NULL DEFAULT 0,
-  status         ana.operation_status NOT NULL DEFAULT 'running',
+  status         {{.StatusType}} NOT NULL DEFAULT 'running',
   target         varchar              NOT NULL,
   key            varchar              NOT NULL,
   payload        bytea                NOT NULL,
   result         bytea,
-  error_message  varchar,
+  error_message  varchar{{if .Partitioned}}
+) PARTITION BY RANGE (expiration);
+
+CREATE TABLE IF NOT EXISTS {{.DefaultPartition}} PARTITION OF {{.Table}} DEFAULT;
+
+CREATE INDEX IF NOT EXISTS {{.TargetKeyIndex}} ON {{.Table}} (target, key);
+{{else}},
 
   PRIMARY KEY(target, key)
 );
-
-CREATE OR REPLACE FUNCTION ana.fetch_or_start(
+{{end}}
+CREATE OR REPLACE FUNCTION {{.FetchOrStart}}(
   _key            varchar,
   _target         varchar,
   _payload        bytea,
   _reference_time timestamptz,
   _timeout        interval,
   _expiration     interval
-) RETURNS ana.tracked_operations
+) RETURNS {{.Table}}
 LANGUAGE plpgsql
 AS $$
 DECLARE
-  _operation ana.tracked_operations;
-BEGIN
+  _operation {{.Table}};
+BEGIN{{if .Partitioned}}
+  PERFORM pg_advisory_xact_lock(hashtext(_target), hashtext(_key));
+{{end}}
   SELECT * INTO _operation
-  FROM ana.tracked_operations
+  FROM {{.Table}}
   WHERE key = _key AND target = _target
   FOR UPDATE;
 
@@ -43,7 +57,7 @@ BEGIN
     RETURN _operation;
   END IF;
 
-  INSERT INTO ana.tracked_operations (
+  INSERT INTO {{.Table}} (
     status,
     key,
     target,
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS {{.Outbox}} (
+  id         bigserial   NOT NULL,
+  topic      varchar     NOT NULL,
+  key        varchar     NOT NULL,
+  payload    bytea       NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(id)
+);
@@ -0,0 +1 @@
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS attempt bigint NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE {{.Table}} ADD COLUMN IF NOT EXISTS result_ref varchar;
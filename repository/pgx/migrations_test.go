@@ -0,0 +1,53 @@
+package pgx
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dalthon/ana/internal/testutil"
+
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(newConfig(nil))
+	testutil.AssertErrorNil(t, err)
+
+	if len(migrations) == 0 {
+		t.Fatalf("Expected to have embedded migrations, but got none.")
+	}
+
+	for i, migration := range migrations {
+		testutil.AssertEqual(t, int64(i+1), migration.version)
+	}
+}
+
+func TestMigrateConcurrently(t *testing.T) {
+	pool := newPool()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- Migrate(context.Background(), pool)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		testutil.AssertErrorNil(t, err)
+	}
+
+	migrations, _ := loadMigrations(newConfig(nil))
+
+	var count int64
+	err := pool.QueryRow(
+		context.Background(),
+		"SELECT COUNT(*) FROM ana.tracked_operations_migrations;",
+	).Scan(&count)
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, int64(len(migrations)), count)
+}
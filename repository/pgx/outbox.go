@@ -0,0 +1,173 @@
+package pgx
+
+import (
+	"context"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var enqueueOutboxQuery string = `
+  INSERT INTO {{.Outbox}} (topic, key, payload)
+  VALUES (@topic, @key, @payload);
+`
+
+var lockOutboxQuery string = `
+  SELECT pg_try_advisory_xact_lock(hashtext(@lock));
+`
+
+var pendingOutboxQuery string = `
+  SELECT id, topic, key, payload, created_at
+  FROM {{.Outbox}}
+  ORDER BY id ASC
+  LIMIT @count;
+`
+
+var deleteOutboxQuery string = `
+  DELETE FROM {{.Outbox}}
+  WHERE id = ANY(@ids);
+`
+
+type OutboxMessage struct {
+	ID        int64
+	Topic     string
+	Key       string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+type Publisher interface {
+	Publish(context.Context, *OutboxMessage) error
+}
+
+// Enqueue inserts a message on outbox within the same transaction of the
+// operation, so that it is only relayed if the operation succeeds.
+func (ctx *PgxContext[P, R]) Enqueue(topic string, key string, payload []byte) error {
+	_, err := ctx.Tx.Exec(
+		ctx.Context,
+		ctx.queries.enqueueOutbox,
+		pgx.NamedArgs{
+			"topic":   topic,
+			"key":     key,
+			"payload": payload,
+		},
+	)
+
+	return err
+}
+
+// OutboxRelay drains outbox messages to a publisher with at-least-once
+// delivery. Only one relay drains a given outbox at a time, publishing messages
+// in the order they were enqueued and stopping at the first failure, so that
+// messages sharing a key are never reordered.
+type OutboxRelay struct {
+	pool      *pgxpool.Pool
+	publisher Publisher
+	config    *config
+	queries   *queries
+}
+
+func NewOutboxRelay(pool *pgxpool.Pool, publisher Publisher, options ...Option) *OutboxRelay {
+	config := newConfig(options)
+
+	return &OutboxRelay{
+		pool:      pool,
+		publisher: publisher,
+		config:    config,
+		queries:   newQueries(config),
+	}
+}
+
+// Drain publishes up to count messages, returning how many were published.
+func (relay *OutboxRelay) Drain(ctx context.Context, count int) (int64, error) {
+	tx, err := relay.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	err = tx.QueryRow(
+		ctx,
+		lockOutboxQuery,
+		pgx.NamedArgs{"lock": relay.config.lock() + ".outbox"},
+	).Scan(&locked)
+
+	if err != nil || !locked {
+		return 0, err
+	}
+
+	messages, err := relay.pending(ctx, tx, count)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]int64, 0, len(messages))
+	var publishErr error
+	for _, message := range messages {
+		if publishErr = relay.publisher.Publish(ctx, message); publishErr != nil {
+			break
+		}
+
+		ids = append(ids, message.ID)
+	}
+
+	if len(ids) == 0 {
+		return 0, publishErr
+	}
+
+	if _, err := tx.Exec(ctx, relay.queries.deleteOutbox, pgx.NamedArgs{"ids": ids}); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return int64(len(ids)), publishErr
+}
+
+// Run drains up to count messages every interval until given context is done.
+// Draining errors are handed to the handler set by WithRelayErrorHandler, and
+// draining is retried on next interval, so that transient failures never stop
+// delivery for good.
+func (relay *OutboxRelay) Run(ctx context.Context, interval time.Duration, count int) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		drained, err := relay.Drain(ctx, count)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil && relay.config.relayErrors != nil {
+			relay.config.relayErrors(err)
+		}
+
+		if err == nil && drained == int64(count) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (relay *OutboxRelay) pending(ctx context.Context, tx pgx.Tx, count int) ([]*OutboxMessage, error) {
+	rows, err := tx.Query(ctx, relay.queries.pendingOutbox, pgx.NamedArgs{"count": count})
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*OutboxMessage, error) {
+		var message OutboxMessage
+		err := row.Scan(&message.ID, &message.Topic, &message.Key, &message.Payload, &message.CreatedAt)
+
+		return &message, err
+	})
+}
@@ -0,0 +1,175 @@
+package pgx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/internal/testutil"
+
+	"testing"
+)
+
+type recordingPublisher struct {
+	messages []*OutboxMessage
+	failOn   string
+}
+
+func (publisher *recordingPublisher) Publish(ctx context.Context, message *OutboxMessage) error {
+	if string(message.Payload) == publisher.failOn {
+		return errors.New("Could not publish")
+	}
+
+	publisher.messages = append(publisher.messages, message)
+	return nil
+}
+
+func TestPgxContextEnqueueOnSuccess(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
+	operation := testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true)
+
+	trackedOperation := repo.FetchOrStart(operation)
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	session := repo.NewSession(operation)
+	testutil.AssertErrorNil(t, session.Context.Enqueue("topic", "message key", []byte("message")))
+	session.Context.Success(trackedOperation)
+
+	publisher := &recordingPublisher{}
+	relay := NewOutboxRelay(pool, publisher)
+
+	drained, err := relay.Drain(context.Background(), 10)
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, int64(1), drained)
+	testutil.AssertEqual(t, "topic", publisher.messages[0].Topic)
+	testutil.AssertEqual(t, "message key", publisher.messages[0].Key)
+	testutil.AssertEqual(t, "message", string(publisher.messages[0].Payload))
+
+	drained, err = relay.Drain(context.Background(), 10)
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, int64(0), drained)
+}
+
+func TestPgxContextEnqueueOnFail(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
+	operation := testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true)
+
+	trackedOperation := repo.FetchOrStart(operation)
+	trackedOperation.Err = errors.New("Something went wrong")
+	session := repo.NewSession(operation)
+	testutil.AssertErrorNil(t, session.Context.Enqueue("topic", "message key", []byte("message")))
+	session.Context.Fail(trackedOperation)
+
+	publisher := &recordingPublisher{}
+	drained, err := NewOutboxRelay(pool, publisher).Drain(context.Background(), 10)
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, int64(0), drained)
+	testutil.AssertEqual(t, a.Failed, repo.FetchOrStart(operation).Status)
+}
+
+func TestOutboxRelayStopsOnFailure(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
+	operation := testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true)
+
+	trackedOperation := repo.FetchOrStart(operation)
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	session := repo.NewSession(operation)
+	for _, payload := range []string{"first", "second", "third"} {
+		testutil.AssertErrorNil(t, session.Context.Enqueue("topic", "message key", []byte(payload)))
+	}
+	session.Context.Success(trackedOperation)
+
+	publisher := &recordingPublisher{failOn: "second"}
+	relay := NewOutboxRelay(pool, publisher)
+
+	drained, err := relay.Drain(context.Background(), 10)
+	testutil.AssertEqual(t, int64(1), drained)
+	if err == nil {
+		t.Fatalf("Expected to have publishing error, but got nil.")
+	}
+
+	publisher.failOn = ""
+	drained, err = relay.Drain(context.Background(), 10)
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, int64(2), drained)
+
+	testutil.AssertEqual(t, 3, len(publisher.messages))
+	for i, payload := range []string{"first", "second", "third"} {
+		testutil.AssertEqual(t, payload, string(publisher.messages[i].Payload))
+	}
+}
+
+type flakyPublisher struct {
+	mutex     sync.Mutex
+	failures  int
+	published []string
+}
+
+func (publisher *flakyPublisher) Publish(ctx context.Context, message *OutboxMessage) error {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	if publisher.failures > 0 {
+		publisher.failures -= 1
+		return errors.New("Could not publish")
+	}
+
+	publisher.published = append(publisher.published, string(message.Payload))
+	return nil
+}
+
+func (publisher *flakyPublisher) count() int {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	return len(publisher.published)
+}
+
+func TestOutboxRelayRunKeepsDraining(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
+	operation := testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true)
+
+	trackedOperation := repo.FetchOrStart(operation)
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	session := repo.NewSession(operation)
+	for _, payload := range []string{"first", "second"} {
+		testutil.AssertErrorNil(t, session.Context.Enqueue("topic", "message key", []byte(payload)))
+	}
+	session.Context.Success(trackedOperation)
+
+	var handled atomic.Int64
+	publisher := &flakyPublisher{failures: 2}
+	relay := NewOutboxRelay(pool, publisher, WithRelayErrorHandler(func(error) { handled.Add(1) }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- relay.Run(ctx, 10*time.Millisecond, 10) }()
+
+	for publisher.count() < 2 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected to run until cancelled, but got \"%v\"", err)
+	}
+
+	testutil.AssertEqual(t, 2, publisher.count())
+	testutil.AssertEqual(t, int64(2), handled.Load())
+}
@@ -0,0 +1,188 @@
+package pgx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const partitionSuffixLayout string = "20060102150405"
+
+var listPartitionsQuery string = `
+  SELECT child.relname
+  FROM pg_inherits
+  JOIN pg_class AS child ON child.oid = pg_inherits.inhrelid
+  WHERE pg_inherits.inhparent = '{{.Table}}'::regclass;
+`
+
+var lockPartitionsQuery string = `
+  SELECT pg_advisory_xact_lock(hashtext(@lock));
+`
+
+var createPartitionQuery string = `
+  CREATE TABLE {{.Partition}} (LIKE {{.Table}} INCLUDING DEFAULTS INCLUDING CONSTRAINTS);
+
+  LOCK TABLE {{.DefaultPartition}} IN ACCESS EXCLUSIVE MODE;
+
+  WITH moved AS (
+    DELETE FROM {{.DefaultPartition}}
+    WHERE expiration >= '{{.From}}' AND expiration < '{{.To}}'
+    RETURNING *
+  )
+  INSERT INTO {{.Partition}} SELECT * FROM moved;
+
+  ALTER TABLE {{.Table}} ATTACH PARTITION {{.Partition}} FOR VALUES FROM ('{{.From}}') TO ('{{.To}}');
+`
+
+var partitionBlobsQuery string = `
+  SELECT result_ref FROM {{.Partition}} WHERE result_ref IS NOT NULL;
+`
+
+var dropPartitionQuery string = `
+  DROP TABLE {{.Partition}};
+`
+
+// MaintainPartitions drops every partition whose range is fully expired, and
+// creates missing ones from current one up to ahead intervals in the future,
+// moving any row already stored on default partition for their ranges.
+//
+// It runs inside a single transaction holding an advisory lock, so it is safe
+// to call it from many replicas at once. Default partition is locked while rows
+// are moved out of it, so that none is inserted there meanwhile. It returns how
+// many partitions were created and dropped.
+func (repo *PgxRepository[P, R]) MaintainPartitions(ahead int) (int64, int64, error) {
+	if !repo.config.partitioned() {
+		return 0, 0, errors.New("Repository is not partitioned")
+	}
+
+	ctx := context.Background()
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, lockPartitionsQuery, pgx.NamedArgs{"lock": repo.config.lock() + ".partitions"}); err != nil {
+		return 0, 0, err
+	}
+
+	var now time.Time
+	if err := tx.QueryRow(ctx, "SELECT NOW();").Scan(&now); err != nil {
+		return 0, 0, err
+	}
+
+	existing, err := repo.partitions(ctx, tx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	interval := repo.config.partitionInterval
+
+	// Partitions are dropped first, locking partitioned table before default
+	// partition, just as inserts do, so that they never deadlock.
+	var dropped int64
+	var blobs []string
+	for from := range existing {
+		if from.Add(interval).After(now) {
+			continue
+		}
+
+		names, err := repo.partitionBlobs(ctx, tx, from)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if _, err := tx.Exec(ctx, repo.config.renderPartition(dropPartitionQuery, from)); err != nil {
+			return 0, 0, err
+		}
+
+		blobs = append(blobs, names...)
+		dropped += 1
+	}
+
+	var created int64
+	current := now.UTC().Truncate(interval)
+	for i := 0; i <= ahead; i++ {
+		from := current.Add(time.Duration(i) * interval)
+		if _, ok := existing[from]; ok {
+			continue
+		}
+
+		if _, err := tx.Exec(ctx, repo.config.renderPartition(createPartitionQuery, from)); err != nil {
+			return 0, 0, err
+		}
+
+		created += 1
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, err
+	}
+
+	return created, dropped, deleteBlobs(ctx, repo.config.blobs, blobs)
+}
+
+func (repo *PgxRepository[P, R]) partitions(ctx context.Context, tx pgx.Tx) (map[time.Time]string, error) {
+	rows, err := tx.Query(ctx, repo.config.render(listPartitionsQuery))
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := make(map[time.Time]string, len(names))
+	for _, name := range names {
+		if from, ok := repo.config.partitionStart(name); ok {
+			partitions[from] = name
+		}
+	}
+
+	return partitions, nil
+}
+
+func (repo *PgxRepository[P, R]) partitionBlobs(ctx context.Context, tx pgx.Tx, from time.Time) ([]string, error) {
+	if repo.config.blobs == nil {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(ctx, repo.config.renderPartition(partitionBlobsQuery, from))
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func (config *config) partitionName(from time.Time) string {
+	return fmt.Sprintf("%s_p%s", config.table, from.UTC().Format(partitionSuffixLayout))
+}
+
+func (config *config) partitionStart(name string) (time.Time, bool) {
+	suffix, found := strings.CutPrefix(name, config.table+"_p")
+	if !found {
+		return time.Time{}, false
+	}
+
+	from, err := time.Parse(partitionSuffixLayout, suffix)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return from, true
+}
+
+func (config *config) renderPartition(text string, from time.Time) string {
+	names := config.names()
+	names["Partition"] = config.identifier(config.partitionName(from))
+	names["From"] = from.UTC().Format(time.RFC3339)
+	names["To"] = from.Add(config.partitionInterval).UTC().Format(time.RFC3339)
+
+	return config.renderWith(text, names)
+}
@@ -0,0 +1,78 @@
+package pgx
+
+import (
+	"context"
+	"time"
+
+	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/internal/testutil"
+
+	"testing"
+)
+
+func TestPartitionNames(t *testing.T) {
+	config := newConfig([]Option{WithTable("short_lived"), WithPartitioning(time.Hour)})
+	from := time.Date(2023, 9, 20, 9, 0, 0, 0, time.UTC)
+
+	name := config.partitionName(from)
+	testutil.AssertEqual(t, "short_lived_p20230920090000", name)
+
+	parsed, ok := config.partitionStart(name)
+	testutil.AssertEqual(t, true, ok)
+	testutil.AssertTimeEqual(t, from, parsed)
+
+	_, ok = config.partitionStart("short_lived_default")
+	testutil.AssertEqual(t, false, ok)
+
+	query := config.renderPartition(createPartitionQuery, from)
+	testutil.AssertContains(t, query, `ATTACH PARTITION "ana"."short_lived_p20230920090000" FOR VALUES FROM ('2023-09-20T09:00:00Z') TO ('2023-09-20T10:00:00Z');`)
+}
+
+func TestPartitionedRepository(t *testing.T) {
+	pool := newPool()
+	options := []Option{WithTable("partitioned_operations"), WithPartitioning(time.Hour)}
+
+	if err := Migrate(context.Background(), pool, options...); err != nil {
+		panic(err)
+	}
+
+	if _, err := pool.Exec(context.Background(), "TRUNCATE ana.partitioned_operations;"); err != nil {
+		panic(err)
+	}
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, options...)
+	_, _, err := repo.MaintainPartitions(0)
+	testutil.AssertErrorNil(t, err)
+
+	past := time.Now().UTC().Truncate(time.Hour).Add(-3 * time.Hour)
+	if _, err := pool.Exec(context.Background(), repo.config.renderPartition(createPartitionQuery, past)); err != nil {
+		panic(err)
+	}
+
+	created, dropped, err := repo.MaintainPartitions(2)
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, int64(2), created)
+	testutil.AssertEqual(t, int64(1), dropped)
+
+	created, dropped, err = repo.MaintainPartitions(2)
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, int64(0), created)
+	testutil.AssertEqual(t, int64(0), dropped)
+
+	operation := testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true)
+
+	trackedOperation := repo.FetchOrStart(operation)
+	testutil.AssertEqual(t, trackedOperation.Status, a.Ready)
+
+	anotherTrackedOperation := repo.FetchOrStart(operation)
+	testutil.AssertEqual(t, anotherTrackedOperation.Status, a.Running)
+	testutil.AssertEqual(t, trackedOperation.Expiration, anotherTrackedOperation.Expiration)
+}
+
+func TestUnpartitionedMaintenance(t *testing.T) {
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](nil)
+
+	if _, _, err := repo.MaintainPartitions(1); err == nil {
+		t.Fatal("Expected unpartitioned repository to fail maintaining partitions")
+	}
+}
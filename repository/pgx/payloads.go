@@ -0,0 +1,56 @@
+package pgx
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+)
+
+// payloadEncoder turns payloads into what is stored on payload column, which
+// is their gob encoding unless repository was built with WithPayloadHash or
+// WithPayloadProjection.
+type payloadEncoder func(payload any) []byte
+
+// WithPayloadHash stores only a SHA-256 hash of payloads, enough to tell
+// whether a key was reused with another payload while keeping them out of the
+// database. Payloads are hashed from their JSON encoding, which is stable even
+// for maps. Tracked operations read from repository have no Payload.
+func WithPayloadHash() Option {
+	return func(config *config) {
+		config.hashedPayload = true
+		config.payloadEncoder = hashPayload
+	}
+}
+
+// WithPayloadProjection stores the payload returned by project instead of the
+// original one, such as a copy with sensitive fields redacted. P must be the
+// payload type of the repository.
+func WithPayloadProjection[P any](project func(*P) *P) Option {
+	return func(config *config) {
+		config.hashedPayload = false
+		config.payloadEncoder = func(payload any) []byte {
+			return serialize(project(payload.(*P)))
+		}
+	}
+}
+
+func hashPayload(payload any) []byte {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		panic("Could not encode data.")
+	}
+
+	hash := sha256.Sum256(encoded)
+	return hash[:]
+}
+
+func encodePayload[P any](encoder payloadEncoder, payload *P) []byte {
+	if encoder == nil {
+		return serialize(payload)
+	}
+
+	if payload == nil {
+		return []byte{}
+	}
+
+	return encoder(payload)
+}
@@ -0,0 +1,81 @@
+package pgx
+
+import (
+	"bytes"
+	"context"
+
+	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/internal/testutil"
+
+	"testing"
+)
+
+func TestHashPayload(t *testing.T) {
+	first := map[string]string{}
+	first["lorem"] = "ipsum"
+	first["dolor"] = "sit"
+
+	second := map[string]string{}
+	second["dolor"] = "sit"
+	second["lorem"] = "ipsum"
+
+	hash := encodePayload(hashPayload, &first)
+	testutil.AssertEqual(t, 32, len(hash))
+
+	if !bytes.Equal(hash, encodePayload(hashPayload, &second)) {
+		t.Fatalf("Expected hashes of equal payloads to be equal")
+	}
+
+	second["lorem"] = "amet"
+	if bytes.Equal(hash, encodePayload(hashPayload, &second)) {
+		t.Fatalf("Expected hashes of distinct payloads to be distinct")
+	}
+
+	testutil.AssertEqual(t, 0, len(encodePayload[testutil.DebugPayload](hashPayload, nil)))
+}
+
+func TestPayloadProjection(t *testing.T) {
+	config := newConfig([]Option{WithPayloadProjection(func(payload *testutil.DebugPayload) *testutil.DebugPayload {
+		return &testutil.DebugPayload{Value: "redacted"}
+	})})
+
+	encoded := encodePayload(config.payloadEncoder, &testutil.DebugPayload{Value: "secret"})
+	testutil.AssertEqual(t, "redacted", deserialize[testutil.DebugPayload](encoded).Value)
+	testutil.AssertEqual(t, false, config.hashedPayload)
+}
+
+func TestPgxRepositoryPayloadHash(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, WithPayloadHash())
+	manager := a.New[testutil.DebugPayload, testutil.DebugResult, pgxCtx](repo)
+	operation := testutil.NewMockedOperation[pgxCtx]("key", "target", "secret", "result", true)
+
+	result, err := manager.Call(operation)
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, "result", result.Value)
+
+	var storedPayload []byte
+	pool.QueryRow(context.Background(), "SELECT payload FROM ana.tracked_operations WHERE key = 'key';").Scan(&storedPayload)
+	testutil.AssertEqual(t, true, bytes.Equal(hashPayload(operation.Payload()), storedPayload))
+
+	trackedOperation := repo.Lookup("key", "target")
+	testutil.AssertEqual(t, a.Finished, trackedOperation.Status)
+	testutil.AssertNil(t, trackedOperation.Payload)
+	testutil.AssertEqual(t, "result", trackedOperation.Result.Value)
+}
+
+func TestPgxRepositoryPayloadProjection(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, WithPayloadProjection(func(payload *testutil.DebugPayload) *testutil.DebugPayload {
+		return &testutil.DebugPayload{Value: "redacted"}
+	}))
+	manager := a.New[testutil.DebugPayload, testutil.DebugResult, pgxCtx](repo)
+
+	_, err := manager.Call(testutil.NewMockedOperation[pgxCtx]("key", "target", "secret", "result", true))
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, "redacted", repo.Lookup("key", "target").Payload.Value)
+}
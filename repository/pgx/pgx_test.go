@@ -2,11 +2,12 @@ package pgx
 
 import (
 	"context"
-	"errors"
 	"os"
 	"time"
 
 	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/anatest"
+	"github.com/dalthon/ana/internal/testutil"
 
 	pgx "github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -14,94 +15,118 @@ import (
 	"testing"
 )
 
-func TestPgxRepositoryFetchOrStart(t *testing.T) {
+type pgxCtx = *PgxContext[testutil.DebugPayload, testutil.DebugResult]
+
+func TestPgxRepositoryLookup(t *testing.T) {
 	pool := newPool()
 	clearDatabase(pool)
 
-	repo := NewPgxRepository[debugPayload, debugResult](pool)
-	operation := newMockedOperation("key", "target", "payload", "result", true)
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
+	operation := testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true)
+
+	testutil.AssertNil(t, repo.Lookup("key", "target"))
 
 	trackedOperation := repo.FetchOrStart(operation)
-	assertEqual(t, trackedOperation.Status, a.Ready)
-	assertEqual(t, trackedOperation.Key, operation.Key())
-	assertEqual(t, trackedOperation.Target, operation.Target())
-	assertEqual(t, trackedOperation.Payload.Value, operation.Payload().Value)
-	assertTimeEqual(t, trackedOperation.ReferenceTime, operation.ReferenceTime())
-	assertNil(t, trackedOperation.Result)
-	assertErrorNil(t, trackedOperation.Err)
-
-	anotherTrackedOperation := repo.FetchOrStart(operation)
-	assertEqual(t, anotherTrackedOperation.Status, a.Running)
-	assertEqual(t, anotherTrackedOperation.Key, operation.Key())
-	assertEqual(t, anotherTrackedOperation.Target, operation.Target())
-	assertEqual(t, anotherTrackedOperation.Payload.Value, operation.Payload().Value)
-	assertTimeEqual(t, anotherTrackedOperation.ReferenceTime, operation.ReferenceTime())
-	assertNil(t, anotherTrackedOperation.Result)
-	assertErrorNil(t, anotherTrackedOperation.Err)
-
-	assertEqual(t, trackedOperation.StartedAt, anotherTrackedOperation.StartedAt)
-	assertEqual(t, trackedOperation.Timeout, anotherTrackedOperation.Timeout)
-	assertEqual(t, trackedOperation.Expiration, anotherTrackedOperation.Expiration)
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	session := repo.NewSession(operation)
+	session.Context.Success(trackedOperation)
+
+	lookedUpOperation := repo.Lookup("key", "target")
+	testutil.AssertEqual(t, lookedUpOperation.Status, a.Finished)
+	testutil.AssertEqual(t, lookedUpOperation.Result.Value, "result")
+	testutil.AssertNil(t, repo.Lookup("key", "another target"))
 }
 
-func TestPgxContextSuccess(t *testing.T) {
+func TestPgxContextExtend(t *testing.T) {
 	pool := newPool()
 	clearDatabase(pool)
 
-	repo := NewPgxRepository[debugPayload, debugResult](pool)
-	operation := newMockedOperation("key", "target", "payload", "result", true)
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
+	operation := testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true)
 
 	trackedOperation := repo.FetchOrStart(operation)
-	assertEqual(t, trackedOperation.Status, a.Ready)
-
-	trackedOperation.Result = &debugResult{"result"}
 	session := repo.NewSession(operation)
-	session.Context.Success(trackedOperation)
+	testutil.AssertErrorNil(t, session.Context.Extend(time.Hour))
 
-	refreshedOperation := repo.FetchOrStart(operation)
-	assertEqual(t, refreshedOperation.Status, a.Finished)
-	assertEqual(t, refreshedOperation.Result.Value, "result")
-	assertErrorNil(t, refreshedOperation.Err)
+	extendedOperation := repo.Lookup("key", "target")
+	if !extendedOperation.Timeout.After(trackedOperation.Timeout.Add(50 * time.Minute)) {
+		t.Fatalf("Expected timeout to be extended from %v, but got %v", trackedOperation.Timeout, extendedOperation.Timeout)
+	}
+
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	session.Context.Success(trackedOperation)
+	testutil.AssertEqual(t, repo.Lookup("key", "target").Status, a.Finished)
 }
 
-func TestPgxContextFail(t *testing.T) {
+func TestPgxContextSuperseded(t *testing.T) {
 	pool := newPool()
 	clearDatabase(pool)
 
-	repo := NewPgxRepository[debugPayload, debugResult](pool)
-	operation := newMockedOperation("key", "target", "payload", "result", true)
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
+	operation := testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true)
 
 	trackedOperation := repo.FetchOrStart(operation)
-	assertEqual(t, trackedOperation.Status, a.Ready)
+	staleSession := repo.NewSession(operation)
+	testutil.AssertEqual(t, int64(1), staleSession.Context.Attempt)
+
+	_, err := staleSession.Context.Tx.Exec(
+		context.Background(),
+		"INSERT INTO ana.outbox (topic, key, payload) VALUES ('stale', 'stale', '');",
+	)
+	testutil.AssertErrorNil(t, err)
 
-	trackedOperation.Err = errors.New("Something went wrong")
 	session := repo.NewSession(operation)
-	session.Context.Fail(trackedOperation)
+	testutil.AssertEqual(t, int64(2), session.Context.Attempt)
+
+	staleOperation := *trackedOperation
+	staleOperation.Result = &testutil.DebugResult{Value: "stale result"}
+	err = staleSession.Context.Success(&staleOperation)
+	if _, ok := err.(*a.SupersededError); !ok {
+		t.Fatalf("Expected to have superseded error, but got \"%v\"", err)
+	}
+
+	var staleCount int64
+	pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM ana.outbox WHERE topic = 'stale';").Scan(&staleCount)
+	testutil.AssertEqual(t, int64(0), staleCount)
+
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	testutil.AssertErrorNil(t, session.Context.Success(trackedOperation))
 
 	refreshedOperation := repo.FetchOrStart(operation)
-	assertEqual(t, refreshedOperation.Status, a.Failed)
-	assertNil(t, refreshedOperation.Result)
-	assertEqual(t, refreshedOperation.Err.Error(), trackedOperation.Err.Error())
+	testutil.AssertEqual(t, refreshedOperation.Status, a.Finished)
+	testutil.AssertEqual(t, refreshedOperation.Result.Value, "result")
 }
 
-func TestPgxContextFailOnSuccess(t *testing.T) {
+func TestPgxRepositoryCustomTable(t *testing.T) {
 	pool := newPool()
+	options := []Option{WithSchema("ana_custom"), WithTable("short_lived")}
+
+	if err := Migrate(context.Background(), pool, options...); err != nil {
+		panic(err)
+	}
+
+	if _, err := pool.Exec(context.Background(), "TRUNCATE ana_custom.short_lived;"); err != nil {
+		panic(err)
+	}
 	clearDatabase(pool)
 
-	repo := NewPgxRepository[debugPayload, debugResult](pool)
-	operation := newMockedOperation("key", "target", "payload", "result", true)
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, options...)
+	defaultRepo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
+	operation := testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true)
 
 	trackedOperation := repo.FetchOrStart(operation)
-	assertEqual(t, trackedOperation.Status, a.Ready)
+	testutil.AssertEqual(t, trackedOperation.Status, a.Ready)
 
-	trackedOperation.Expiration = time.Now().Add(-10 * time.Second)
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
 	session := repo.NewSession(operation)
 	session.Context.Success(trackedOperation)
 
 	refreshedOperation := repo.FetchOrStart(operation)
-	assertEqual(t, refreshedOperation.Status, a.Failed)
-	assertNil(t, refreshedOperation.Result)
-	assertEqual(t, refreshedOperation.Err.Error(), "Operation expired")
+	testutil.AssertEqual(t, refreshedOperation.Status, a.Finished)
+	testutil.AssertEqual(t, refreshedOperation.Result.Value, "result")
+
+	defaultOperation := defaultRepo.FetchOrStart(operation)
+	testutil.AssertEqual(t, defaultOperation.Status, a.Ready)
 }
 
 func TestRepositoryDeleteExpired(t *testing.T) {
@@ -130,21 +155,21 @@ func TestRepositoryDeleteExpired(t *testing.T) {
 		pgx.NamedArgs{},
 	)
 
-	repo := NewPgxRepository[debugPayload, debugResult](pool)
-	assertEqual(t, int64(2), repo.DeleteExpired(a.Finished, 10))
-	assertEqual(t, int64(0), repo.DeleteExpired(a.Finished, 10))
-	assertEqual(t, int64(1), repo.DeleteExpired(a.Running, 1))
-	assertEqual(t, int64(1), repo.DeleteExpired(a.Running, 1))
-	assertEqual(t, int64(0), repo.DeleteExpired(a.Running, 1))
-	assertEqual(t, int64(2), repo.DeleteExpired(a.Failed, 2))
-	assertEqual(t, int64(0), repo.DeleteExpired(a.Failed, 2))
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
+	testutil.AssertEqual(t, int64(2), repo.DeleteExpired(a.Finished, 10))
+	testutil.AssertEqual(t, int64(0), repo.DeleteExpired(a.Finished, 10))
+	testutil.AssertEqual(t, int64(1), repo.DeleteExpired(a.Running, 1))
+	testutil.AssertEqual(t, int64(1), repo.DeleteExpired(a.Running, 1))
+	testutil.AssertEqual(t, int64(0), repo.DeleteExpired(a.Running, 1))
+	testutil.AssertEqual(t, int64(2), repo.DeleteExpired(a.Failed, 2))
+	testutil.AssertEqual(t, int64(0), repo.DeleteExpired(a.Failed, 2))
 }
 
 func TestRepositoryFailExpiredStillRunning(t *testing.T) {
 	pool := newPool()
 	clearDatabase(pool)
 
-	repo := NewPgxRepository[debugPayload, debugResult](pool)
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
 
 	pool.Exec(
 		context.Background(),
@@ -167,8 +192,8 @@ func TestRepositoryFailExpiredStillRunning(t *testing.T) {
     `,
 		pgx.NamedArgs{},
 	)
-	assertEqual(t, int64(2), repo.FailExpiredStillRunning(10))
-	assertEqual(t, int64(0), repo.FailExpiredStillRunning(10))
+	testutil.AssertEqual(t, int64(2), repo.FailExpiredStillRunning(10))
+	testutil.AssertEqual(t, int64(0), repo.FailExpiredStillRunning(10))
 
 	pool.Exec(
 		context.Background(),
@@ -183,9 +208,9 @@ func TestRepositoryFailExpiredStillRunning(t *testing.T) {
     `,
 		pgx.NamedArgs{},
 	)
-	assertEqual(t, int64(1), repo.FailExpiredStillRunning(1))
-	assertEqual(t, int64(1), repo.FailExpiredStillRunning(1))
-	assertEqual(t, int64(0), repo.FailExpiredStillRunning(1))
+	testutil.AssertEqual(t, int64(1), repo.FailExpiredStillRunning(1))
+	testutil.AssertEqual(t, int64(1), repo.FailExpiredStillRunning(1))
+	testutil.AssertEqual(t, int64(0), repo.FailExpiredStillRunning(1))
 
 	pool.Exec(
 		context.Background(),
@@ -200,15 +225,15 @@ func TestRepositoryFailExpiredStillRunning(t *testing.T) {
     `,
 		pgx.NamedArgs{},
 	)
-	assertEqual(t, int64(2), repo.FailExpiredStillRunning(2))
-	assertEqual(t, int64(0), repo.FailExpiredStillRunning(2))
+	testutil.AssertEqual(t, int64(2), repo.FailExpiredStillRunning(2))
+	testutil.AssertEqual(t, int64(0), repo.FailExpiredStillRunning(2))
 }
 
 func TestRepositoryFailTimedOutStillRunning(t *testing.T) {
 	pool := newPool()
 	clearDatabase(pool)
 
-	repo := NewPgxRepository[debugPayload, debugResult](pool)
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
 
 	pool.Exec(
 		context.Background(),
@@ -231,8 +256,8 @@ func TestRepositoryFailTimedOutStillRunning(t *testing.T) {
     `,
 		pgx.NamedArgs{},
 	)
-	assertEqual(t, int64(2), repo.FailTimedOutStillRunning(10))
-	assertEqual(t, int64(0), repo.FailTimedOutStillRunning(10))
+	testutil.AssertEqual(t, int64(2), repo.FailTimedOutStillRunning(10))
+	testutil.AssertEqual(t, int64(0), repo.FailTimedOutStillRunning(10))
 
 	pool.Exec(
 		context.Background(),
@@ -247,9 +272,9 @@ func TestRepositoryFailTimedOutStillRunning(t *testing.T) {
     `,
 		pgx.NamedArgs{},
 	)
-	assertEqual(t, int64(1), repo.FailTimedOutStillRunning(1))
-	assertEqual(t, int64(1), repo.FailTimedOutStillRunning(1))
-	assertEqual(t, int64(0), repo.FailTimedOutStillRunning(1))
+	testutil.AssertEqual(t, int64(1), repo.FailTimedOutStillRunning(1))
+	testutil.AssertEqual(t, int64(1), repo.FailTimedOutStillRunning(1))
+	testutil.AssertEqual(t, int64(0), repo.FailTimedOutStillRunning(1))
 
 	pool.Exec(
 		context.Background(),
@@ -264,8 +289,35 @@ func TestRepositoryFailTimedOutStillRunning(t *testing.T) {
     `,
 		pgx.NamedArgs{},
 	)
-	assertEqual(t, int64(2), repo.FailTimedOutStillRunning(2))
-	assertEqual(t, int64(0), repo.FailTimedOutStillRunning(2))
+	testutil.AssertEqual(t, int64(2), repo.FailTimedOutStillRunning(2))
+	testutil.AssertEqual(t, int64(0), repo.FailTimedOutStillRunning(2))
+}
+
+func TestPgxRepositorySuite(t *testing.T) {
+	anatest.RunRepositorySuite(t, func(t *testing.T) *anatest.Backend[*PgxContext[anatest.Payload, anatest.Result]] {
+		pool := newPool()
+		clearDatabase(pool)
+		t.Cleanup(pool.Close)
+
+		for _, query := range []string{"CREATE TABLE IF NOT EXISTS ana.marks (value text NOT NULL);", "TRUNCATE ana.marks;"} {
+			if _, err := pool.Exec(context.Background(), query); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return &anatest.Backend[*PgxContext[anatest.Payload, anatest.Result]]{
+			Repository: NewPgxRepository[anatest.Payload, anatest.Result](pool),
+			Write: func(ctx *PgxContext[anatest.Payload, anatest.Result], value string) error {
+				_, err := ctx.Tx.Exec(ctx.Context, "INSERT INTO ana.marks (value) VALUES (@value);", pgx.NamedArgs{"value": value})
+				return err
+			},
+			Written: func(value string) bool {
+				var count int64
+				pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM ana.marks WHERE value = @value;", pgx.NamedArgs{"value": value}).Scan(&count)
+				return count > 0
+			},
+		}
+	})
 }
 
 func newPool() *pgxpool.Pool {
@@ -274,11 +326,15 @@ func newPool() *pgxpool.Pool {
 		panic(err)
 	}
 
+	if err := Migrate(context.Background(), pool); err != nil {
+		panic(err)
+	}
+
 	return pool
 }
 
 func clearDatabase(pool *pgxpool.Pool) {
-	if _, err := pool.Exec(context.Background(), "TRUNCATE ana.tracked_operations;"); err != nil {
+	if _, err := pool.Exec(context.Background(), "TRUNCATE ana.tracked_operations, ana.outbox;"); err != nil {
 		panic(err)
 	}
 }
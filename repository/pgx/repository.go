@@ -2,6 +2,10 @@ package pgx
 
 import (
 	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
 
 	a "github.com/dalthon/ana"
 	pgx "github.com/jackc/pgx/v5"
@@ -19,8 +23,10 @@ var fetchOrStartQuery string = `
     timeout,
     expiration,
     result,
-    error_message
-  FROM ana.fetch_or_start(
+    result_ref,
+    error_message,
+    error
+  FROM {{.FetchOrStart}}(
     @key,
     @target,
     @payload,
@@ -30,26 +36,94 @@ var fetchOrStartQuery string = `
   );
 `
 
+var fetchOrStartBatchQuery string = `
+  SELECT
+    operation.status,
+    operation.key,
+    operation.target,
+    operation.payload,
+    operation.reference_time,
+    operation.started_at,
+    operation.timeout,
+    operation.expiration,
+    operation.result,
+    operation.result_ref,
+    operation.error_message,
+    operation.error
+  FROM unnest(
+    @keys::varchar[],
+    @targets::varchar[],
+    @payloads::bytea[],
+    @reference_times::timestamptz[],
+    @timeouts::interval[],
+    @expirations::interval[]
+  ) WITH ORDINALITY AS input(key, target, payload, reference_time, timeout, expiration, ordinality)
+  CROSS JOIN LATERAL {{.FetchOrStart}}(
+    input.key,
+    input.target,
+    input.payload,
+    input.reference_time,
+    input.timeout,
+    input.expiration
+  ) AS operation
+  ORDER BY input.ordinality;
+`
+
+var lookupQuery string = `
+  SELECT
+    status,
+    key,
+    target,
+    payload,
+    reference_time,
+    started_at,
+    timeout,
+    expiration,
+    result,
+    result_ref,
+    error_message,
+    error
+  FROM {{.Table}}
+  WHERE key = @key AND target = @target;
+`
+
+// Claiming happens outside session transaction, so that a new attempt is seen
+// right away by any older attempt still running.
+var claimTrackedOperationQuery string = `
+  UPDATE {{.Table}}
+  SET
+    attempt    = attempt + 1,
+    status     = 'running',
+    started_at = NOW(),
+    timeout    = NOW() + NULLIF(@timeout, '0'::interval)
+  WHERE
+    key = @key AND target = @target
+  RETURNING attempt;
+`
+
+// A key share lock still blocks concurrent fetch or start calls and reapers,
+// but allows Extend to update timeout from another connection.
 var lockTrackOperationQuery string = `
   SELECT *
-  FROM ana.tracked_operations
+  FROM {{.Table}}
   WHERE
     key            = @key    AND
     target         = @target AND
     reference_time = @reference_time
-  FOR UPDATE;
+  FOR KEY SHARE;
 `
 
 var failTimedOutStillRunningQuery string = `
-  UPDATE ana.tracked_operations AS operation
+  UPDATE {{.Table}} AS operation
   SET
     status        = 'failed',
     finished_at   = NOW(),
     error_count   = error_count + 1,
-    error_message = 'Operation timed out'
+    error_message = 'Operation timed out',
+    error         = NULL
   FROM (
     SELECT key, target
-    FROM ana.tracked_operations AS t
+    FROM {{.Table}} AS t
     WHERE
       t.status = 'running' AND
       t.timeout < NOW()
@@ -61,15 +135,16 @@ var failTimedOutStillRunningQuery string = `
 `
 
 var failExpiredStillRunningQuery string = `
-  UPDATE ana.tracked_operations AS operation
+  UPDATE {{.Table}} AS operation
   SET
     status        = 'failed',
     finished_at   = NOW(),
     error_count   = error_count + 1,
-    error_message = 'Operation expired'
+    error_message = 'Operation expired',
+    error         = NULL
   FROM (
     SELECT key, target
-    FROM ana.tracked_operations AS t
+    FROM {{.Table}} AS t
     WHERE
       t.status = 'running' AND
       t.expiration < NOW()
@@ -81,10 +156,10 @@ var failExpiredStillRunningQuery string = `
 `
 
 var deleteExpiredQuery string = `
-  DELETE FROM ana.tracked_operations AS operation
+  DELETE FROM {{.Table}} AS operation
   USING (
     SELECT key, target
-    FROM ana.tracked_operations AS t
+    FROM {{.Table}} AS t
     WHERE
       t.status = @status AND
       t.expiration < NOW()
@@ -92,25 +167,91 @@ var deleteExpiredQuery string = `
     LIMIT @count
     FOR UPDATE SKIP LOCKED
   ) AS expired
-  WHERE operation.key = expired.key AND operation.target = expired.target;
+  WHERE operation.key = expired.key AND operation.target = expired.target
+  RETURNING operation.result_ref;
 `
 
+var statementTimeoutQuery string = `
+  SELECT
+    current_setting('statement_timeout'),
+    set_config('statement_timeout', @statement_timeout, true);
+`
+
+var readOnlyQuery string = `
+  SET LOCAL transaction_read_only = on;
+`
+
+// TxOptionsOperation is implemented by operations needing transaction options
+// other than repository ones.
+type TxOptionsOperation interface {
+	TxOptions() pgx.TxOptions
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 type PgxRepository[P any, R any] struct {
-	pool *pgxpool.Pool
+	pool    *pgxpool.Pool
+	tx      pgx.Tx
+	config  *config
+	queries *queries
+	clock   a.Clock
 }
 
-func NewPgxRepository[P any, R any](pool *pgxpool.Pool) *PgxRepository[P, R] {
-	return &PgxRepository[P, R]{pool: pool}
+func NewPgxRepository[P any, R any](pool *pgxpool.Pool, options ...Option) *PgxRepository[P, R] {
+	config := newConfig(options)
+
+	clock := config.clock
+	if config.databaseClock {
+		clock = NewDatabaseClock(pool, DatabaseClockRefresh)
+	}
+
+	return &PgxRepository[P, R]{
+		pool:    pool,
+		config:  config,
+		queries: newQueries(config),
+		clock:   clock,
+	}
+}
+
+func (repo *PgxRepository[P, R]) Clock() a.Clock {
+	return repo.clock
+}
+
+// InTx returns a repository that fetches, starts and runs operations within
+// given caller owned transaction, so that they are committed or rolled back
+// along with it. Sessions run on savepoints, so that failed operations are
+// still recorded without aborting the transaction. Operations are never
+// extended nor retried, and their isolation level is the one of given
+// transaction.
+func (repo *PgxRepository[P, R]) InTx(tx pgx.Tx) *PgxRepository[P, R] {
+	return &PgxRepository[P, R]{
+		pool:    repo.pool,
+		tx:      tx,
+		config:  repo.config,
+		queries: repo.queries,
+		clock:   repo.clock,
+	}
+}
+
+func (repo *PgxRepository[P, R]) querier() querier {
+	if repo.tx != nil {
+		return repo.tx
+	}
+
+	return repo.pool
 }
 
 func (repo *PgxRepository[P, R]) FetchOrStart(operation a.Operation[P, R, *PgxContext[P, R]]) *a.TrackedOperation[P, R] {
-	rows, err := repo.pool.Query(
+	rows, err := repo.querier().Query(
 		context.Background(),
-		fetchOrStartQuery,
+		repo.queries.fetchOrStart,
 		pgx.NamedArgs{
 			"key":            operation.Key(),
 			"target":         operation.Target(),
-			"payload":        serialize(operation.Payload()),
+			"payload":        encodePayload(repo.config.payloadEncoder, operation.Payload()),
 			"reference_time": operation.ReferenceTime(),
 			"timeout":        operation.Timeout(),
 			"expiration":     operation.Expiration(),
@@ -121,27 +262,200 @@ func (repo *PgxRepository[P, R]) FetchOrStart(operation a.Operation[P, R, *PgxCo
 		panic(err)
 	}
 
-	return rowsToTrackedOperation[P, R](rows)
+	return rowsToTrackedOperation[P, R](rows, repo.config)
+}
+
+// FetchOrStartBatch fetches or starts all given operations on a single round
+// trip. Operations are locked sorted by target and key, so that concurrent
+// batches never deadlock each other.
+func (repo *PgxRepository[P, R]) FetchOrStartBatch(operations []a.Operation[P, R, *PgxContext[P, R]]) []*a.TrackedOperation[P, R] {
+	order := make([]int, len(operations))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		left, right := operations[order[i]], operations[order[j]]
+		if left.Target() != right.Target() {
+			return left.Target() < right.Target()
+		}
+
+		return left.Key() < right.Key()
+	})
+
+	keys := make([]string, len(operations))
+	targets := make([]string, len(operations))
+	payloads := make([][]byte, len(operations))
+	referenceTimes := make([]time.Time, len(operations))
+	timeouts := make([]time.Duration, len(operations))
+	expirations := make([]time.Duration, len(operations))
+
+	for position, i := range order {
+		keys[position] = operations[i].Key()
+		targets[position] = operations[i].Target()
+		payloads[position] = encodePayload(repo.config.payloadEncoder, operations[i].Payload())
+		referenceTimes[position] = operations[i].ReferenceTime()
+		timeouts[position] = operations[i].Timeout()
+		expirations[position] = operations[i].Expiration()
+	}
+
+	rows, err := repo.querier().Query(
+		context.Background(),
+		repo.queries.fetchOrStartBatch,
+		pgx.NamedArgs{
+			"keys":            keys,
+			"targets":         targets,
+			"payloads":        payloads,
+			"reference_times": referenceTimes,
+			"timeouts":        timeouts,
+			"expirations":     expirations,
+		},
+	)
+
+	if err != nil {
+		panic(err)
+	}
+
+	sorted := rowsToTrackedOperations[P, R](rows, repo.config)
+	trackedOperations := make([]*a.TrackedOperation[P, R], len(operations))
+	for position, i := range order {
+		trackedOperations[i] = sorted[position]
+	}
+
+	return trackedOperations
+}
+
+func (repo *PgxRepository[P, R]) Lookup(key string, target string) *a.TrackedOperation[P, R] {
+	rows, err := repo.querier().Query(
+		context.Background(),
+		repo.queries.lookup,
+		pgx.NamedArgs{"key": key, "target": target},
+	)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return rowsToTrackedOperation[P, R](rows, repo.config)
 }
 
 func (repo *PgxRepository[P, R]) NewSession(operation a.Operation[P, R, *PgxContext[P, R]]) *a.Session[P, R, *PgxContext[P, R]] {
-	context := context.Background()
-	outerTx, _ := repo.pool.Begin(context)
-	tx, _ := outerTx.Begin(context)
+	ctx := context.Background()
+
+	var attempt int64
+	err := repo.querier().QueryRow(ctx, repo.queries.claimTrackedOperation, pgx.NamedArgs{
+		"key":     operation.Key(),
+		"target":  operation.Target(),
+		"timeout": operation.Timeout(),
+	}).Scan(&attempt)
+
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		panic(err)
+	}
+
+	txOptions := repo.config.txOptions
+	if txOptionsOperation, ok := a.OperationAs[TxOptionsOperation](operation); ok {
+		txOptions = txOptionsOperation.TxOptions()
+	}
+
+	// Operation outcome is recorded on outer transaction, so read only access
+	// is set only within inner one, after its lock is taken.
+	readOnly := txOptions.AccessMode == pgx.ReadOnly
+	txOptions.AccessMode = ""
+
+	outerTx, err := repo.beginOuterTx(ctx, txOptions)
+	if err != nil {
+		panic(err)
+	}
 
-	tx.Exec(context, lockTrackOperationQuery, pgx.NamedArgs{
+	// Sessions whose transactions could not be set up as asked are never run.
+	abort := func(err error) {
+		outerTx.Rollback(ctx)
+		panic(err)
+	}
+
+	tx, err := outerTx.Begin(ctx)
+	if err != nil {
+		abort(err)
+	}
+
+	_, err = tx.Exec(ctx, repo.queries.lockTrackOperation, pgx.NamedArgs{
 		"key":            operation.Key(),
 		"target":         operation.Target(),
 		"reference_time": operation.ReferenceTime(),
 	})
+	if err != nil {
+		abort(err)
+	}
+
+	var statementTimeout *string
+	if operation.Timeout() != time.Duration(0) {
+		var previous, current string
+		err := tx.QueryRow(ctx, statementTimeoutQuery, pgx.NamedArgs{
+			"statement_timeout": strconv.FormatInt((operation.Timeout() + StatementTimeoutSlack).Milliseconds(), 10),
+		}).Scan(&previous, &current)
+		if err != nil {
+			abort(err)
+		}
+
+		statementTimeout = &previous
+	}
+
+	if readOnly {
+		if _, err := tx.Exec(ctx, readOnlyQuery); err != nil {
+			abort(err)
+		}
+	}
+
+	pgxContext := NewPgxContext[P, R](outerTx, tx, ctx)
+	pgxContext.queries = repo.queries
+	pgxContext.clock = repo.clock
+	pgxContext.blobs = repo.config.blobs
+	pgxContext.blobThreshold = repo.config.blobThreshold
+	pgxContext.payloadEncoder = repo.config.payloadEncoder
+	pgxContext.readOnly = readOnly
+	pgxContext.statementTimeout = statementTimeout
+	pgxContext.key = operation.Key()
+	pgxContext.target = operation.Target()
+	pgxContext.Attempt = attempt
+
+	// Within a caller owned transaction, operation rows stay locked until it
+	// finishes, and serialization failures abort it as a whole. Contexts get no
+	// deadline there either, since canceling them would close caller connection.
+	if repo.tx == nil {
+		pgxContext.pool = repo.pool
+		pgxContext.extendable = extendable(txOptions.IsoLevel)
+		pgxContext.retries = repo.config.serializationRetries
+
+		if operation.Timeout() != time.Duration(0) {
+			pgxContext.lease = newLeaseContext(ctx, operation.Timeout())
+			pgxContext.Context, pgxContext.cancel = pgxContext.lease, pgxContext.lease.stop
+		}
+	}
+
+	return a.NewSession(operation, pgxContext)
+}
+
+// Extensions update operation rows on connections of their own, which sessions
+// whose snapshots were taken before would then fail to record their outcomes on
+// with serialization failures, so they are only made on read committed ones.
+func extendable(isoLevel pgx.TxIsoLevel) bool {
+	return isoLevel == "" || isoLevel == pgx.ReadCommitted || isoLevel == pgx.ReadUncommitted
+}
+
+// Within a caller owned transaction, sessions start on a savepoint instead.
+func (repo *PgxRepository[P, R]) beginOuterTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	if repo.tx != nil {
+		return repo.tx.Begin(ctx)
+	}
 
-	return a.NewSession(operation, NewPgxContext[P, R](outerTx, tx, context))
+	return repo.pool.BeginTx(ctx, txOptions)
 }
 
 func (repo *PgxRepository[P, R]) FailTimedOutStillRunning(count int) int64 {
 	info, err := repo.pool.Exec(
 		context.Background(),
-		failTimedOutStillRunningQuery,
+		repo.queries.failTimedOutStillRunning,
 		pgx.NamedArgs{"count": count},
 	)
 
@@ -155,7 +469,7 @@ func (repo *PgxRepository[P, R]) FailTimedOutStillRunning(count int) int64 {
 func (repo *PgxRepository[P, R]) FailExpiredStillRunning(count int) int64 {
 	info, err := repo.pool.Exec(
 		context.Background(),
-		failExpiredStillRunningQuery,
+		repo.queries.failExpiredStillRunning,
 		pgx.NamedArgs{"count": count},
 	)
 
@@ -167,9 +481,11 @@ func (repo *PgxRepository[P, R]) FailExpiredStillRunning(count int) int64 {
 }
 
 func (repo *PgxRepository[P, R]) DeleteExpired(status a.TrackedOperationStatus, count int) int64 {
-	info, err := repo.pool.Exec(
-		context.Background(),
-		deleteExpiredQuery,
+	ctx := context.Background()
+
+	rows, err := repo.pool.Query(
+		ctx,
+		repo.queries.deleteExpired,
 		pgx.NamedArgs{
 			"status": trackedStatusToPgStatus(status),
 			"count":  count,
@@ -180,7 +496,23 @@ func (repo *PgxRepository[P, R]) DeleteExpired(status a.TrackedOperationStatus,
 		panic(err)
 	}
 
-	return info.RowsAffected()
+	refs, err := pgx.CollectRows(rows, pgx.RowTo[*string])
+	if err != nil {
+		panic(err)
+	}
+
+	names := []string{}
+	for _, ref := range refs {
+		if ref != nil {
+			names = append(names, *ref)
+		}
+	}
+
+	if err := deleteBlobs(ctx, repo.config.blobs, names); err != nil {
+		panic(err)
+	}
+
+	return int64(len(refs))
 }
 
 func trackedStatusToPgStatus(status a.TrackedOperationStatus) string {
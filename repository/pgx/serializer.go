@@ -2,8 +2,11 @@ package pgx
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
+	"encoding/json"
 	"errors"
+	"time"
 
 	a "github.com/dalthon/ana"
 	pgx "github.com/jackc/pgx/v5"
@@ -39,27 +42,64 @@ func deserialize[S any](encoded []byte) *S {
 	return &decoded
 }
 
-func rowsToTrackedOperation[P any, R any](rows pgx.Rows) *a.TrackedOperation[P, R] {
+func rowsToTrackedOperation[P any, R any](rows pgx.Rows, config *config) *a.TrackedOperation[P, R] {
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			panic(err)
+		}
+
+		return nil
+	}
+
+	return scanTrackedOperation[P, R](rows, config)
+}
+
+func rowsToTrackedOperations[P any, R any](rows pgx.Rows, config *config) []*a.TrackedOperation[P, R] {
+	defer rows.Close()
+
+	operations := []*a.TrackedOperation[P, R]{}
+	for rows.Next() {
+		operations = append(operations, scanTrackedOperation[P, R](rows, config))
+	}
+
+	if err := rows.Err(); err != nil {
+		panic(err)
+	}
+
+	return operations
+}
+
+func scanTrackedOperation[P any, R any](rows pgx.Rows, config *config) *a.TrackedOperation[P, R] {
 	var operation a.TrackedOperation[P, R]
 	var status string
-	var errorMessage string
+	var timeout *time.Time
+	var expiration *time.Time
+	var resultRef *string
+	var errorMessage *string
+	var encodedErr []byte
 	var encodedPayload []byte
 	var encodedResult []byte
 
-	rows.Next()
-	rows.Scan(
+	err := rows.Scan(
 		&status,
 		&operation.Key,
 		&operation.Target,
 		&encodedPayload,
 		&operation.ReferenceTime,
 		&operation.StartedAt,
-		&operation.Timeout,
-		&operation.Expiration,
+		&timeout,
+		&expiration,
 		&encodedResult,
+		&resultRef,
 		&errorMessage,
+		&encodedErr,
 	)
-	rows.Close()
+
+	if err != nil {
+		panic(err)
+	}
 
 	switch status {
 	case "ready":
@@ -72,12 +112,47 @@ func rowsToTrackedOperation[P any, R any](rows pgx.Rows) *a.TrackedOperation[P,
 		operation.Status = a.Failed
 	}
 
-	if errorMessage != "" {
-		operation.Err = errors.New(errorMessage)
+	if timeout != nil {
+		operation.Timeout = *timeout
+	}
+
+	if expiration != nil {
+		operation.Expiration = *expiration
+	}
+
+	if len(encodedErr) > 0 {
+		operation.Err = decodeError(config.errors, encodedErr)
+	} else if errorMessage != nil && *errorMessage != "" {
+		operation.Err = errors.New(*errorMessage)
+	}
+
+	if resultRef != nil {
+		encodedResult = loadBlob(context.Background(), config.blobs, *resultRef)
+	}
+
+	if !config.hashedPayload {
+		operation.Payload = deserialize[P](encodedPayload)
 	}
 
-	operation.Payload = deserialize[P](encodedPayload)
 	operation.Result = deserialize[R](encodedResult)
 
 	return &operation
 }
+
+func encodeError(err error) ([]byte, error) {
+	encoded, err := a.EncodeError(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(encoded)
+}
+
+func decodeError(registry *a.ErrorRegistry, raw []byte) error {
+	var encoded a.EncodedError
+	if err := json.Unmarshal(raw, &encoded); err != nil {
+		panic("Could not decode error.")
+	}
+
+	return registry.Decode(&encoded)
+}
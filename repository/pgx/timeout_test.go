@@ -0,0 +1,148 @@
+package pgx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/internal/testutil"
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"testing"
+)
+
+func TestPgxRepositoryStatementTimeout(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, WithTxOptions(pgx.TxOptions{IsoLevel: pgx.RepeatableRead}))
+	manager := a.New[testutil.DebugPayload, testutil.DebugResult, pgxCtx](repo)
+
+	operation := &txOptionsOperation{
+		MockedOperation: testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true),
+		call: func(o *txOptionsOperation, ctx pgxCtx) (*testutil.DebugResult, error) {
+			_, err := ctx.Tx.Exec(context.Background(), "SELECT pg_sleep(2);")
+			return nil, err
+		},
+	}
+	operation.WithTimeout(200 * time.Millisecond)
+
+	_, err := manager.Call(operation)
+	var pgErr *pgconn.PgError
+	if !errors.Is(err, a.ErrTimeout) || !errors.As(err, &pgErr) || pgErr.Code != "57014" {
+		t.Fatalf("Expected to have timeout error canceling statement, but got \"%v\"", err)
+	}
+
+	assertTimedOut(t, repo)
+}
+
+func TestPgxRepositoryDeadline(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, WithTxOptions(pgx.TxOptions{IsoLevel: pgx.RepeatableRead}))
+	manager := a.New[testutil.DebugPayload, testutil.DebugResult, pgxCtx](repo)
+
+	operation := &txOptionsOperation{
+		MockedOperation: testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true),
+		call: func(o *txOptionsOperation, ctx pgxCtx) (*testutil.DebugResult, error) {
+			if deadline, ok := ctx.Context.Deadline(); !ok || deadline.After(time.Now().Add(o.Timeout())) {
+				return nil, fmt.Errorf("Expected context to have operation timeout as deadline, but got \"%v\"", deadline)
+			}
+
+			_, err := ctx.Tx.Exec(ctx.Context, "SELECT pg_sleep(1);")
+			return nil, err
+		},
+	}
+	operation.WithTimeout(200 * time.Millisecond)
+
+	_, err := manager.Call(operation)
+	if !errors.Is(err, a.ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected to have timeout error canceling context, but got \"%v\"", err)
+	}
+
+	assertTimedOut(t, repo)
+}
+
+func TestPgxRepositoryLateSuccess(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, WithTxOptions(pgx.TxOptions{IsoLevel: pgx.RepeatableRead}))
+	manager := a.New[testutil.DebugPayload, testutil.DebugResult, pgxCtx](repo)
+
+	operation := &txOptionsOperation{
+		MockedOperation: testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true),
+		call: func(o *txOptionsOperation, ctx pgxCtx) (*testutil.DebugResult, error) {
+			time.Sleep(o.Timeout() + o.Timeout()/2)
+			return &testutil.DebugResult{Value: "result"}, nil
+		},
+	}
+	operation.WithTimeout(200 * time.Millisecond)
+
+	if result, err := manager.Call(operation); result != nil || !errors.Is(err, a.ErrTimeout) {
+		t.Fatalf("Expected to have timeout error, but got \"%v\" and \"%v\"", result, err)
+	}
+
+	assertTimedOut(t, repo)
+}
+
+func TestPgxRepositoryExtendedDeadline(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
+	manager := a.New[testutil.DebugPayload, testutil.DebugResult, pgxCtx](repo)
+
+	operation := &txOptionsOperation{
+		MockedOperation: testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true),
+		call: func(o *txOptionsOperation, ctx pgxCtx) (*testutil.DebugResult, error) {
+			time.Sleep(3 * o.Timeout())
+
+			var value string
+			err := ctx.Tx.QueryRow(ctx.Context, "SELECT 'result';").Scan(&value)
+			return &testutil.DebugResult{Value: value}, err
+		},
+	}
+	operation.WithTimeout(300 * time.Millisecond)
+
+	result, err := manager.Call(operation)
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, "result", result.Value)
+	testutil.AssertEqual(t, a.Finished, repo.Lookup("key", "target").Status)
+}
+
+func assertTimedOut(t *testing.T, repo *PgxRepository[testutil.DebugPayload, testutil.DebugResult]) {
+	t.Helper()
+
+	trackedOperation := repo.Lookup("key", "target")
+	testutil.AssertEqual(t, a.Failed, trackedOperation.Status)
+	if !errors.Is(trackedOperation.Err, a.ErrTimeout) {
+		t.Fatalf("Expected to have recorded timeout error, but got \"%v\"", trackedOperation.Err)
+	}
+}
+
+func TestLeaseContext(t *testing.T) {
+	ctx := newLeaseContext(context.Background(), 30*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	ctx.extend(40 * time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+	testutil.AssertErrorNil(t, ctx.Err())
+	if deadline, ok := ctx.Deadline(); !ok || !deadline.After(time.Now()) {
+		t.Fatalf("Expected to have deadline pushed forward, but got \"%v\"", deadline)
+	}
+
+	<-ctx.Done()
+	testutil.AssertEqual(t, context.DeadlineExceeded, ctx.Err())
+
+	ctx.extend(time.Minute)
+	testutil.AssertEqual(t, context.DeadlineExceeded, ctx.Err())
+
+	stopped := newLeaseContext(context.Background(), time.Minute)
+	stopped.stop()
+	testutil.AssertEqual(t, context.Canceled, stopped.Err())
+}
@@ -0,0 +1,184 @@
+package pgx
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/internal/testutil"
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"testing"
+)
+
+type txOptionsOperation struct {
+	*testutil.MockedOperation[pgxCtx]
+	options pgx.TxOptions
+	calls   int
+	call    func(*txOptionsOperation, pgxCtx) (*testutil.DebugResult, error)
+}
+
+func (o *txOptionsOperation) TxOptions() pgx.TxOptions {
+	return o.options
+}
+
+func (o *txOptionsOperation) Call(ctx pgxCtx) (*testutil.DebugResult, error) {
+	o.calls += 1
+	return o.call(o, ctx)
+}
+
+func showSetting(setting string) func(*txOptionsOperation, pgxCtx) (*testutil.DebugResult, error) {
+	return func(o *txOptionsOperation, ctx pgxCtx) (*testutil.DebugResult, error) {
+		var value string
+		if err := ctx.Tx.QueryRow(ctx.Context, "SHOW "+setting+";").Scan(&value); err != nil {
+			return nil, err
+		}
+
+		return &testutil.DebugResult{Value: value}, nil
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	ctx := &PgxContext[testutil.DebugPayload, testutil.DebugResult]{retries: 1}
+	serializationErr := fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: serializationFailure})
+
+	testutil.AssertEqual(t, true, ctx.ShouldRetry(serializationErr, 0))
+	testutil.AssertEqual(t, false, ctx.ShouldRetry(serializationErr, 1))
+	testutil.AssertEqual(t, false, ctx.ShouldRetry(&pgconn.PgError{Code: "23505"}, 0))
+	testutil.AssertEqual(t, false, ctx.ShouldRetry(fmt.Errorf("Something went wrong"), 0))
+}
+
+func TestPgxRepositoryTxOptions(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, WithTxOptions(pgx.TxOptions{IsoLevel: pgx.RepeatableRead}))
+	manager := a.New[testutil.DebugPayload, testutil.DebugResult, pgxCtx](
+		repo,
+		a.WithKeyPolicy(a.KeyPolicy{Case: a.LowerKeyCase}),
+	)
+
+	result, err := manager.Call(&txOptionsOperation{
+		MockedOperation: testutil.NewMockedOperation[pgxCtx]("first", "target", "payload", "result", true),
+		call:            showSetting("transaction_isolation"),
+	})
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, "repeatable read", result.Value)
+
+	result, err = manager.Call(&txOptionsOperation{
+		MockedOperation: testutil.NewMockedOperation[pgxCtx]("Second", "target", "payload", "result", true),
+		options:         pgx.TxOptions{IsoLevel: pgx.Serializable},
+		call:            showSetting("transaction_isolation"),
+	})
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, "serializable", result.Value)
+}
+
+func TestPgxRepositorySlowRepeatableRead(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, WithTxOptions(pgx.TxOptions{IsoLevel: pgx.RepeatableRead}))
+	manager := a.New[testutil.DebugPayload, testutil.DebugResult, pgxCtx](repo)
+
+	operation := &txOptionsOperation{
+		MockedOperation: testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true),
+		call: func(o *txOptionsOperation, ctx pgxCtx) (*testutil.DebugResult, error) {
+			time.Sleep(o.Timeout() / 2)
+			return &testutil.DebugResult{Value: "result"}, nil
+		},
+	}
+	operation.WithTimeout(600 * time.Millisecond)
+
+	result, err := manager.Call(operation)
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, "result", result.Value)
+	testutil.AssertEqual(t, 1, operation.calls)
+	testutil.AssertEqual(t, a.Finished, repo.Lookup("key", "target").Status)
+}
+
+func TestPgxRepositoryInvalidTxOptions(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
+	manager := a.New[testutil.DebugPayload, testutil.DebugResult, pgxCtx](repo)
+
+	operation := &txOptionsOperation{
+		MockedOperation: testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true),
+		options:         pgx.TxOptions{IsoLevel: "bogus"},
+		call:            showSetting("transaction_isolation"),
+	}
+
+	if _, err := manager.Call(operation); !errors.Is(err, a.ErrPanic) {
+		t.Fatalf("Expected to have panic error, but got \"%v\"", err)
+	}
+	testutil.AssertEqual(t, 0, operation.calls)
+}
+
+func TestExtendable(t *testing.T) {
+	testutil.AssertEqual(t, true, extendable(""))
+	testutil.AssertEqual(t, true, extendable(pgx.ReadCommitted))
+	testutil.AssertEqual(t, false, extendable(pgx.RepeatableRead))
+	testutil.AssertEqual(t, false, extendable(pgx.Serializable))
+}
+
+func TestPgxRepositoryReadOnly(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool)
+	manager := a.New[testutil.DebugPayload, testutil.DebugResult, pgxCtx](repo)
+
+	result, err := manager.Call(&txOptionsOperation{
+		MockedOperation: testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true),
+		options:         pgx.TxOptions{AccessMode: pgx.ReadOnly},
+		call:            showSetting("transaction_read_only"),
+	})
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, "on", result.Value)
+
+	trackedOperation := repo.Lookup("key", "target")
+	testutil.AssertEqual(t, a.Finished, trackedOperation.Status)
+	testutil.AssertEqual(t, "on", trackedOperation.Result.Value)
+}
+
+func TestPgxRepositorySerializationRetries(t *testing.T) {
+	pool := newPool()
+	clearDatabase(pool)
+
+	repo := NewPgxRepository[testutil.DebugPayload, testutil.DebugResult](pool, WithSerializationRetries(2))
+	manager := a.New[testutil.DebugPayload, testutil.DebugResult, pgxCtx](repo)
+
+	operation := &txOptionsOperation{
+		MockedOperation: testutil.NewMockedOperation[pgxCtx]("key", "target", "payload", "result", true),
+		call: func(o *txOptionsOperation, ctx pgxCtx) (*testutil.DebugResult, error) {
+			if o.calls < 3 {
+				return nil, &pgconn.PgError{Code: serializationFailure}
+			}
+
+			return &testutil.DebugResult{Value: "result"}, nil
+		},
+	}
+
+	result, err := manager.Call(operation)
+	testutil.AssertErrorNil(t, err)
+	testutil.AssertEqual(t, "result", result.Value)
+	testutil.AssertEqual(t, 3, operation.calls)
+
+	operation = &txOptionsOperation{
+		MockedOperation: testutil.NewMockedOperation[pgxCtx]("another", "target", "payload", "result", true),
+		call: func(o *txOptionsOperation, ctx pgxCtx) (*testutil.DebugResult, error) {
+			return nil, &pgconn.PgError{Code: serializationFailure}
+		},
+	}
+
+	_, err = manager.Call(operation)
+	testutil.AssertEqual(t, 3, operation.calls)
+	testutil.AssertEqual(t, a.Failed, repo.Lookup("another", "target").Status)
+	if err == nil {
+		t.Fatalf("Expected to have serialization failure")
+	}
+}
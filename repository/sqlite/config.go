@@ -0,0 +1,96 @@
+package sqlite
+
+import (
+	"strings"
+	"text/template"
+
+	a "github.com/dalthon/ana"
+)
+
+const defaultTable string = "tracked_operations"
+
+type Option func(*config)
+
+func WithTable(table string) Option {
+	return func(config *config) {
+		config.table = table
+	}
+}
+
+// WithClock sets the clock used by repository on every timeout and expiration
+// decision, since SQLite has no clock shared among processes.
+func WithClock(clock a.Clock) Option {
+	return func(config *config) {
+		config.clock = clock
+	}
+}
+
+type config struct {
+	table string
+	clock a.Clock
+}
+
+func newConfig(options []Option) *config {
+	config := &config{
+		table: defaultTable,
+		clock: a.SystemClock,
+	}
+
+	for _, option := range options {
+		option(config)
+	}
+
+	return config
+}
+
+func (config *config) identifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (config *config) names() map[string]any {
+	return map[string]any{
+		"Table":           config.identifier(config.table),
+		"Migrations":      config.identifier(config.table + "_migrations"),
+		"TimeoutIndex":    config.identifier(config.table + "_timeout_idx"),
+		"ExpirationIndex": config.identifier(config.table + "_expiration_idx"),
+	}
+}
+
+func (config *config) render(text string) string {
+	var builder strings.Builder
+
+	tmpl := template.Must(template.New("").Parse(text))
+	if err := tmpl.Execute(&builder, config.names()); err != nil {
+		panic(err)
+	}
+
+	return builder.String()
+}
+
+type queries struct {
+	insertTrackedOperation   string
+	selectTrackedOperation   string
+	claimTrackedOperation    string
+	lockTrackOperation       string
+	failTimedOutStillRunning string
+	failExpiredStillRunning  string
+	deleteExpired            string
+	finishTrackedOperation   string
+	failTrackedOperation     string
+}
+
+var defaultQueries *queries = newQueries(newConfig(nil))
+
+func newQueries(config *config) *queries {
+	return &queries{
+		insertTrackedOperation:   config.render(insertTrackedOperationQuery),
+		selectTrackedOperation:   config.render(selectTrackedOperationQuery),
+		claimTrackedOperation:    config.render(claimTrackedOperationQuery),
+		lockTrackOperation:       config.render(lockTrackOperationQuery),
+		failTimedOutStillRunning: config.render(failTimedOutStillRunningQuery),
+		failExpiredStillRunning:  config.render(failExpiredStillRunningQuery),
+		deleteExpired:            config.render(deleteExpiredQuery),
+		finishTrackedOperation:   config.render(finishTrackedOperationQuery),
+		failTrackedOperation:     config.render(failTrackedOperationQuery),
+	}
+}
@@ -0,0 +1,126 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	a "github.com/dalthon/ana"
+)
+
+var finishTrackedOperationQuery string = `
+  UPDATE {{.Table}}
+  SET
+    payload       = @payload,
+    result        = @result,
+    finished_at   = @now,
+    status        = 'finished',
+    error_message = NULL
+  WHERE
+    key = @key AND target = @target AND attempt = @attempt;
+`
+
+var failTrackedOperationQuery string = `
+  UPDATE {{.Table}}
+  SET
+    payload       = @payload,
+    result        = NULL,
+    finished_at   = @now,
+    status        = 'failed',
+    timeout       = @now,
+    error_message = @error_message,
+    error_count   = error_count + 1
+  WHERE
+    key = @key AND target = @target AND attempt = @attempt;
+`
+
+// Operation writes happen within a savepoint, so that they can be rolled back
+// while operation failure is still recorded on the same transaction.
+var (
+	savepointQuery         string = "SAVEPOINT operation;"
+	releaseSavepointQuery  string = "RELEASE operation;"
+	rollbackSavepointQuery string = "ROLLBACK TO operation; RELEASE operation;"
+)
+
+type SqliteContext[P any, R any] struct {
+	queries *queries
+	clock   a.Clock
+	Tx      *sql.Tx
+	Context context.Context
+	Attempt int64
+}
+
+func NewSqliteContext[P any, R any](tx *sql.Tx, context context.Context) *SqliteContext[P, R] {
+	return &SqliteContext[P, R]{queries: defaultQueries, clock: a.SystemClock, Tx: tx, Context: context}
+}
+
+func (ctx *SqliteContext[P, R]) Success(operation *a.TrackedOperation[P, R]) error {
+	if !operation.Expiration.IsZero() && ctx.clock.Now().After(operation.Expiration) {
+		operation.Err = errors.New("Operation expired")
+		return ctx.Fail(operation)
+	}
+
+	if _, err := ctx.Tx.ExecContext(ctx.Context, releaseSavepointQuery); err != nil {
+		ctx.Tx.Rollback()
+		return err
+	}
+
+	return ctx.finish(
+		operation,
+		ctx.queries.finishTrackedOperation,
+		sql.Named("key", operation.Key),
+		sql.Named("target", operation.Target),
+		sql.Named("attempt", ctx.Attempt),
+		sql.Named("now", ctx.clock.Now().UnixNano()),
+		sql.Named("payload", serialize(operation.Payload)),
+		sql.Named("result", serialize(operation.Result)),
+	)
+}
+
+func (ctx *SqliteContext[P, R]) Fail(operation *a.TrackedOperation[P, R]) error {
+	if _, err := ctx.Tx.ExecContext(ctx.Context, rollbackSavepointQuery); err != nil {
+		ctx.Tx.Rollback()
+		return err
+	}
+
+	return ctx.finish(
+		operation,
+		ctx.queries.failTrackedOperation,
+		sql.Named("key", operation.Key),
+		sql.Named("target", operation.Target),
+		sql.Named("attempt", ctx.Attempt),
+		sql.Named("now", ctx.clock.Now().UnixNano()),
+		sql.Named("payload", serialize(operation.Payload)),
+		sql.Named("error_message", operation.Err.Error()),
+	)
+}
+
+// CommitWork releases operation savepoint and commits its transaction.
+func (ctx *SqliteContext[P, R]) CommitWork() error {
+	if _, err := ctx.Tx.ExecContext(ctx.Context, releaseSavepointQuery); err != nil {
+		ctx.Tx.Rollback()
+		return err
+	}
+
+	return ctx.Tx.Commit()
+}
+
+// finish rolls back attempts whose row was taken over by a newer one.
+func (ctx *SqliteContext[P, R]) finish(operation *a.TrackedOperation[P, R], query string, args ...any) error {
+	result, err := ctx.Tx.ExecContext(ctx.Context, query, args...)
+	if err != nil {
+		ctx.Tx.Rollback()
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		ctx.Tx.Rollback()
+		if err != nil {
+			return err
+		}
+
+		return a.NewSupersededError(operation.Target, operation.Key)
+	}
+
+	return ctx.Tx.Commit()
+}
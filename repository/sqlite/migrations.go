@@ -0,0 +1,142 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var createMigrationsTableQuery string = `
+  CREATE TABLE IF NOT EXISTS {{.Migrations}} (
+    version    integer NOT NULL,
+    name       text    NOT NULL,
+    applied_at integer NOT NULL DEFAULT (strftime('%s', 'now')),
+
+    PRIMARY KEY(version)
+  );
+`
+
+var appliedMigrationsQuery string = `
+  SELECT version FROM {{.Migrations}};
+`
+
+var insertMigrationQuery string = `
+  INSERT INTO {{.Migrations}} (version, name)
+  VALUES (@version, @name);
+`
+
+type migration struct {
+	version int64
+	name    string
+	script  string
+}
+
+// Migrate applies every embedded migration not yet recorded on database.
+//
+// Migrations are forward-only and run inside a single write transaction, so it
+// is safe to call it from many processes at once. It must receive the same
+// options given to NewSqliteRepository.
+func Migrate(ctx context.Context, db *sql.DB, options ...Option) error {
+	config := newConfig(options)
+
+	migrations, err := loadMigrations(config)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, config.render(createMigrationsTableQuery)); err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(ctx, tx, config)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if applied[migration.version] {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, migration.script); err != nil {
+			return fmt.Errorf("migration %s failed: %w", migration.name, err)
+		}
+
+		_, err := tx.ExecContext(
+			ctx,
+			config.render(insertMigrationQuery),
+			sql.Named("version", migration.version),
+			sql.Named("name", migration.name),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func appliedMigrations(ctx context.Context, tx *sql.Tx, config *config) (map[int64]bool, error) {
+	rows, err := tx.QueryContext(ctx, config.render(appliedMigrationsQuery))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]bool{}
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+func loadMigrations(config *config) ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+
+		version, err := strconv.ParseInt(strings.SplitN(name, "_", 2)[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration name %s", entry.Name())
+		}
+
+		script, err := fs.ReadFile(migrationFiles, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, migration{version, name, config.render(string(script))})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS {{.Table}} (
+  reference_time integer NOT NULL,
+  started_at     integer NOT NULL,
+  finished_at    integer,
+  timeout        integer,
+  expiration     integer,
+  error_count    integer NOT NULL DEFAULT 0,
+  status         text    NOT NULL DEFAULT 'running',
+  target         text    NOT NULL,
+  key            text    NOT NULL,
+  payload        blob    NOT NULL,
+  result         blob,
+  error_message  text,
+  attempt        integer NOT NULL DEFAULT 0,
+
+  PRIMARY KEY(target, key),
+  CHECK(status IN ('ready', 'running', 'finished', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS {{.TimeoutIndex}} ON {{.Table}} (status, timeout);
+
+CREATE INDEX IF NOT EXISTS {{.ExpirationIndex}} ON {{.Table}} (status, expiration);
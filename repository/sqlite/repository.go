@@ -0,0 +1,415 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	a "github.com/dalthon/ana"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+var insertTrackedOperationQuery string = `
+  INSERT INTO {{.Table}} (
+    status,
+    key,
+    target,
+    payload,
+    reference_time,
+    started_at,
+    timeout,
+    expiration
+  ) VALUES (
+    'running',
+    @key,
+    @target,
+    @payload,
+    @reference_time,
+    @now,
+    @timeout,
+    @expiration
+  )
+  ON CONFLICT (target, key) DO NOTHING
+  RETURNING
+    'ready',
+    key,
+    target,
+    payload,
+    reference_time,
+    started_at,
+    timeout,
+    expiration,
+    result,
+    error_message;
+`
+
+var selectTrackedOperationQuery string = `
+  SELECT
+    status,
+    key,
+    target,
+    payload,
+    reference_time,
+    started_at,
+    timeout,
+    expiration,
+    result,
+    error_message
+  FROM {{.Table}}
+  WHERE key = @key AND target = @target;
+`
+
+// Claiming happens on a transaction of its own, but it needs the write lock that
+// sessions hold while their operations run, so it waits for any older attempt to
+// finish first, or fails once busy timeout is reached. Attempt numbers are still
+// checked when outcomes are recorded, but never fence a running attempt here.
+var claimTrackedOperationQuery string = `
+  UPDATE {{.Table}}
+  SET
+    attempt    = attempt + 1,
+    status     = 'running',
+    started_at = @now,
+    timeout    = @timeout
+  WHERE
+    key = @key AND target = @target
+  RETURNING attempt;
+`
+
+// Touching the row takes database write lock right away, even when session
+// transaction was not begun as immediate.
+var lockTrackOperationQuery string = `
+  UPDATE {{.Table}}
+  SET attempt = attempt
+  WHERE
+    key            = @key    AND
+    target         = @target AND
+    reference_time = @reference_time;
+`
+
+var failTimedOutStillRunningQuery string = `
+  UPDATE {{.Table}}
+  SET
+    status        = 'failed',
+    finished_at   = @now,
+    error_count   = error_count + 1,
+    error_message = 'Operation timed out'
+  WHERE rowid IN (
+    SELECT rowid
+    FROM {{.Table}}
+    WHERE
+      status = 'running' AND
+      timeout < @now
+    ORDER BY timeout ASC
+    LIMIT @count
+  );
+`
+
+var failExpiredStillRunningQuery string = `
+  UPDATE {{.Table}}
+  SET
+    status        = 'failed',
+    finished_at   = @now,
+    error_count   = error_count + 1,
+    error_message = 'Operation expired'
+  WHERE rowid IN (
+    SELECT rowid
+    FROM {{.Table}}
+    WHERE
+      status = 'running' AND
+      expiration < @now
+    ORDER BY expiration ASC
+    LIMIT @count
+  );
+`
+
+var deleteExpiredQuery string = `
+  DELETE FROM {{.Table}}
+  WHERE rowid IN (
+    SELECT rowid
+    FROM {{.Table}}
+    WHERE
+      status = @status AND
+      expiration < @now
+    ORDER BY expiration ASC
+    LIMIT @count
+  );
+`
+
+const DefaultBusyTimeout time.Duration = 5 * time.Second
+
+// Open opens a SQLite database at given path that begins every transaction
+// with BEGIN IMMEDIATE, waiting for the write lock up to busyTimeout, or
+// DefaultBusyTimeout when it is not positive, instead of failing on lock
+// upgrades.
+func Open(path string, busyTimeout time.Duration) (*sql.DB, error) {
+	if busyTimeout <= 0 {
+		busyTimeout = DefaultBusyTimeout
+	}
+
+	query := url.Values{}
+	query.Set("_txlock", "immediate")
+	query.Set("_journal_mode", "WAL")
+	query.Set("_busy_timeout", fmt.Sprint(busyTimeout.Milliseconds()))
+
+	return sql.Open("sqlite3", "file:"+(&url.URL{Path: path}).EscapedPath()+"?"+query.Encode())
+}
+
+// SqliteRepository keeps tracked operations on SQLite. A session holds the
+// database write lock while its operation runs, so sessions are serialized.
+// Fetch or start calls waiting on it past busy timeout fall back to reading
+// tracked operations, so that duplicates of running operations get them as
+// running, but calls for any other operation panic with SQLITE_BUSY, which
+// managers return as *ana.PanicError. So busy timeout should be longer than
+// operation timeouts.
+type SqliteRepository[P any, R any] struct {
+	db      *sql.DB
+	config  *config
+	queries *queries
+}
+
+func NewSqliteRepository[P any, R any](db *sql.DB, options ...Option) *SqliteRepository[P, R] {
+	config := newConfig(options)
+
+	return &SqliteRepository[P, R]{
+		db:      db,
+		config:  config,
+		queries: newQueries(config),
+	}
+}
+
+func (repo *SqliteRepository[P, R]) Clock() a.Clock {
+	return repo.config.clock
+}
+
+func (repo *SqliteRepository[P, R]) FetchOrStart(operation a.Operation[P, R, *SqliteContext[P, R]]) *a.TrackedOperation[P, R] {
+	ctx := context.Background()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return repo.fetchWhileBusy(err, operation)[0]
+	}
+	defer tx.Rollback()
+
+	trackedOperation := repo.fetchOrStart(ctx, tx, operation)
+	if err := tx.Commit(); err != nil {
+		panic(err)
+	}
+
+	return trackedOperation
+}
+
+// FetchOrStartBatch fetches or starts all given operations on a single
+// transaction.
+func (repo *SqliteRepository[P, R]) FetchOrStartBatch(operations []a.Operation[P, R, *SqliteContext[P, R]]) []*a.TrackedOperation[P, R] {
+	ctx := context.Background()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return repo.fetchWhileBusy(err, operations...)
+	}
+	defer tx.Rollback()
+
+	trackedOperations := make([]*a.TrackedOperation[P, R], len(operations))
+	for i, operation := range operations {
+		trackedOperations[i] = repo.fetchOrStart(ctx, tx, operation)
+	}
+
+	if err := tx.Commit(); err != nil {
+		panic(err)
+	}
+
+	return trackedOperations
+}
+
+func (repo *SqliteRepository[P, R]) Lookup(key string, target string) *a.TrackedOperation[P, R] {
+	rows, err := repo.db.QueryContext(
+		context.Background(),
+		repo.queries.selectTrackedOperation,
+		sql.Named("key", key),
+		sql.Named("target", target),
+	)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return rowsToTrackedOperation[P, R](rows)
+}
+
+func (repo *SqliteRepository[P, R]) NewSession(operation a.Operation[P, R, *SqliteContext[P, R]]) *a.Session[P, R, *SqliteContext[P, R]] {
+	ctx := context.Background()
+	now := repo.config.clock.Now()
+
+	var attempt int64
+	err := repo.db.QueryRowContext(
+		ctx,
+		repo.queries.claimTrackedOperation,
+		sql.Named("key", operation.Key()),
+		sql.Named("target", operation.Target()),
+		sql.Named("now", now.UnixNano()),
+		sql.Named("timeout", timeAfter(now, operation.Timeout())),
+	).Scan(&attempt)
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		panic(err)
+	}
+
+	tx := repo.begin(ctx)
+
+	_, err = tx.ExecContext(
+		ctx,
+		repo.queries.lockTrackOperation,
+		sql.Named("key", operation.Key()),
+		sql.Named("target", operation.Target()),
+		sql.Named("reference_time", operation.ReferenceTime().UnixNano()),
+	)
+	if err != nil {
+		tx.Rollback()
+		panic(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, savepointQuery); err != nil {
+		tx.Rollback()
+		panic(err)
+	}
+
+	sqliteContext := NewSqliteContext[P, R](tx, ctx)
+	sqliteContext.queries = repo.queries
+	sqliteContext.clock = repo.config.clock
+	sqliteContext.Attempt = attempt
+
+	return a.NewSession(operation, sqliteContext)
+}
+
+// Reapers return errors, instead of panicking with them, since they are likely
+// to be locked out by running sessions.
+func (repo *SqliteRepository[P, R]) FailTimedOutStillRunning(count int) (int64, error) {
+	return repo.exec(
+		repo.queries.failTimedOutStillRunning,
+		sql.Named("now", repo.config.clock.Now().UnixNano()),
+		sql.Named("count", count),
+	)
+}
+
+func (repo *SqliteRepository[P, R]) FailExpiredStillRunning(count int) (int64, error) {
+	return repo.exec(
+		repo.queries.failExpiredStillRunning,
+		sql.Named("now", repo.config.clock.Now().UnixNano()),
+		sql.Named("count", count),
+	)
+}
+
+func (repo *SqliteRepository[P, R]) DeleteExpired(status a.TrackedOperationStatus, count int) (int64, error) {
+	sqliteStatus, err := trackedStatusToSqliteStatus(status)
+	if err != nil {
+		return 0, err
+	}
+
+	return repo.exec(
+		repo.queries.deleteExpired,
+		sql.Named("now", repo.config.clock.Now().UnixNano()),
+		sql.Named("status", sqliteStatus),
+		sql.Named("count", count),
+	)
+}
+
+func (repo *SqliteRepository[P, R]) fetchOrStart(ctx context.Context, tx *sql.Tx, operation a.Operation[P, R, *SqliteContext[P, R]]) *a.TrackedOperation[P, R] {
+	now := repo.config.clock.Now()
+	referenceTime := operation.ReferenceTime()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		repo.queries.insertTrackedOperation,
+		sql.Named("key", operation.Key()),
+		sql.Named("target", operation.Target()),
+		sql.Named("payload", serialize(operation.Payload())),
+		sql.Named("reference_time", referenceTime.UnixNano()),
+		sql.Named("now", now.UnixNano()),
+		sql.Named("timeout", timeAfter(now, operation.Timeout())),
+		sql.Named("expiration", timeAfter(referenceTime, operation.Expiration())),
+	)
+
+	if err != nil {
+		panic(err)
+	}
+
+	if trackedOperation := rowsToTrackedOperation[P, R](rows); trackedOperation != nil {
+		return trackedOperation
+	}
+
+	rows, err = tx.QueryContext(
+		ctx,
+		repo.queries.selectTrackedOperation,
+		sql.Named("key", operation.Key()),
+		sql.Named("target", operation.Target()),
+	)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return rowsToTrackedOperation[P, R](rows)
+}
+
+// fetchWhileBusy reads given operations without the write lock, which WAL mode
+// allows while sessions hold it, panicking with err unless all of them exist.
+func (repo *SqliteRepository[P, R]) fetchWhileBusy(err error, operations ...a.Operation[P, R, *SqliteContext[P, R]]) []*a.TrackedOperation[P, R] {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrBusy {
+		panic(err)
+	}
+
+	trackedOperations := make([]*a.TrackedOperation[P, R], len(operations))
+	for i, operation := range operations {
+		if trackedOperations[i] = repo.Lookup(operation.Key(), operation.Target()); trackedOperations[i] == nil {
+			panic(err)
+		}
+	}
+
+	return trackedOperations
+}
+
+func (repo *SqliteRepository[P, R]) begin(ctx context.Context) *sql.Tx {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return tx
+}
+
+func (repo *SqliteRepository[P, R]) exec(query string, args ...any) (int64, error) {
+	result, err := repo.db.ExecContext(context.Background(), query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// timeAfter stores zero durations as NULL.
+func timeAfter(base time.Time, duration time.Duration) sql.NullInt64 {
+	if duration == time.Duration(0) {
+		return sql.NullInt64{}
+	}
+
+	return sql.NullInt64{Int64: base.Add(duration).UnixNano(), Valid: true}
+}
+
+func trackedStatusToSqliteStatus(status a.TrackedOperationStatus) (string, error) {
+	switch status {
+	case a.Ready:
+		return "ready", nil
+	case a.Running:
+		return "running", nil
+	case a.Finished:
+		return "finished", nil
+	case a.Failed:
+		return "failed", nil
+	default:
+		return "", fmt.Errorf("Unknown tracked operation status %d", status)
+	}
+}
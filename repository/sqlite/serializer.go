@@ -0,0 +1,116 @@
+package sqlite
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+	"time"
+
+	a "github.com/dalthon/ana"
+)
+
+func serialize[S any](value *S) []byte {
+	if value == nil {
+		return []byte{}
+	}
+
+	var buffer bytes.Buffer
+	encoder := gob.NewEncoder(&buffer)
+
+	if encoder.Encode(value) != nil {
+		panic("Could not encode data.")
+	}
+
+	return buffer.Bytes()
+}
+
+func deserialize[S any](encoded []byte) *S {
+	if len(encoded) == 0 {
+		return nil
+	}
+
+	decoder := gob.NewDecoder(bytes.NewBuffer(encoded))
+	var decoded S
+
+	if decoder.Decode(&decoded) != nil {
+		panic("Could not decode data.")
+	}
+
+	return &decoded
+}
+
+func rowsToTrackedOperation[P any, R any](rows *sql.Rows) *a.TrackedOperation[P, R] {
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			panic(err)
+		}
+
+		return nil
+	}
+
+	return scanTrackedOperation[P, R](rows)
+}
+
+func scanTrackedOperation[P any, R any](rows *sql.Rows) *a.TrackedOperation[P, R] {
+	var operation a.TrackedOperation[P, R]
+	var status string
+	var referenceTime int64
+	var startedAt int64
+	var timeout sql.NullInt64
+	var expiration sql.NullInt64
+	var errorMessage sql.NullString
+	var encodedPayload []byte
+	var encodedResult []byte
+
+	err := rows.Scan(
+		&status,
+		&operation.Key,
+		&operation.Target,
+		&encodedPayload,
+		&referenceTime,
+		&startedAt,
+		&timeout,
+		&expiration,
+		&encodedResult,
+		&errorMessage,
+	)
+
+	if err != nil {
+		panic(err)
+	}
+
+	switch status {
+	case "ready":
+		operation.Status = a.Ready
+	case "running":
+		operation.Status = a.Running
+	case "finished":
+		operation.Status = a.Finished
+	case "failed":
+		operation.Status = a.Failed
+	}
+
+	if errorMessage.String != "" {
+		operation.Err = errors.New(errorMessage.String)
+	}
+
+	operation.ReferenceTime = time.Unix(0, referenceTime)
+	operation.StartedAt = time.Unix(0, startedAt)
+	operation.Timeout = nullableTime(timeout)
+	operation.Expiration = nullableTime(expiration)
+	operation.Payload = deserialize[P](encodedPayload)
+	operation.Result = deserialize[R](encodedResult)
+
+	return &operation
+}
+
+func nullableTime(value sql.NullInt64) time.Time {
+	if !value.Valid {
+		return time.Time{}
+	}
+
+	return time.Unix(0, value.Int64)
+}
@@ -0,0 +1,262 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/anatest"
+	"github.com/dalthon/ana/internal/testutil"
+	sqlite3 "github.com/mattn/go-sqlite3"
+
+	"testing"
+)
+
+type sqliteCtx = *SqliteContext[testutil.DebugPayload, testutil.DebugResult]
+
+func TestSqliteRepositoryFetchOrStartWithoutDeadlines(t *testing.T) {
+	repo := NewSqliteRepository[testutil.DebugPayload, testutil.DebugResult](newDatabase(t))
+	operation := testutil.NewMockedOperation[sqliteCtx]("key", "target", "payload", "result", true)
+	operation.WithTimeout(0).WithExpiration(0)
+
+	trackedOperation := repo.FetchOrStart(operation)
+	testutil.AssertEqual(t, trackedOperation.Status, a.Ready)
+	testutil.AssertEqual(t, true, trackedOperation.Timeout.IsZero())
+	testutil.AssertEqual(t, true, trackedOperation.Expiration.IsZero())
+}
+
+func TestSqliteRepositoryLookup(t *testing.T) {
+	repo := NewSqliteRepository[testutil.DebugPayload, testutil.DebugResult](newDatabase(t))
+	operation := testutil.NewMockedOperation[sqliteCtx]("key", "target", "payload", "result", true)
+
+	testutil.AssertNil(t, repo.Lookup("key", "target"))
+
+	trackedOperation := repo.FetchOrStart(operation)
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	session := repo.NewSession(operation)
+	testutil.AssertErrorNil(t, session.Context.Success(trackedOperation))
+
+	lookedUpOperation := repo.Lookup("key", "target")
+	testutil.AssertEqual(t, lookedUpOperation.Status, a.Finished)
+	testutil.AssertEqual(t, lookedUpOperation.Result.Value, "result")
+	testutil.AssertNil(t, repo.Lookup("key", "another target"))
+}
+
+func TestSqliteContextSuperseded(t *testing.T) {
+	repo := NewSqliteRepository[testutil.DebugPayload, testutil.DebugResult](newDatabase(t))
+	operation := testutil.NewMockedOperation[sqliteCtx]("key", "target", "payload", "result", true)
+
+	trackedOperation := repo.FetchOrStart(operation)
+	session := repo.NewSession(operation)
+
+	_, err := session.Context.Tx.Exec("UPDATE tracked_operations SET attempt = attempt + 1;")
+	testutil.AssertErrorNil(t, err)
+
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	err = session.Context.Success(trackedOperation)
+	if _, ok := err.(*a.SupersededError); !ok {
+		t.Fatalf("Expected to have superseded error, but got \"%v\"", err)
+	}
+
+	refreshedOperation := repo.Lookup("key", "target")
+	testutil.AssertEqual(t, refreshedOperation.Status, a.Running)
+	testutil.AssertNil(t, refreshedOperation.Result)
+}
+
+func TestSqliteRepositoryCustomTable(t *testing.T) {
+	db := newDatabase(t, WithTable("short_lived"))
+	repo := NewSqliteRepository[testutil.DebugPayload, testutil.DebugResult](db, WithTable("short_lived"))
+	operation := testutil.NewMockedOperation[sqliteCtx]("key", "target", "payload", "result", true)
+
+	trackedOperation := repo.FetchOrStart(operation)
+	testutil.AssertEqual(t, trackedOperation.Status, a.Ready)
+
+	trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+	session := repo.NewSession(operation)
+	testutil.AssertErrorNil(t, session.Context.Success(trackedOperation))
+
+	var count int64
+	db.QueryRow("SELECT COUNT(*) FROM short_lived WHERE status = 'finished';").Scan(&count)
+	testutil.AssertEqual(t, int64(1), count)
+	testutil.AssertEqual(t, true, tableExists(db, "short_lived_migrations"))
+}
+
+func TestSqliteRepositoryDeleteExpired(t *testing.T) {
+	clock := a.NewFakeClock(time.Now())
+	reaped := affected(t)
+	repo := NewSqliteRepository[testutil.DebugPayload, testutil.DebugResult](newDatabase(t), WithClock(clock))
+
+	for _, key := range []string{"first", "second", "third"} {
+		operation := testutil.NewMockedOperation[sqliteCtx](key, "target", "payload", "result", true)
+		trackedOperation := repo.FetchOrStart(operation)
+		trackedOperation.Result = &testutil.DebugResult{Value: "result"}
+		testutil.AssertErrorNil(t, repo.NewSession(operation).Context.Success(trackedOperation))
+	}
+	repo.FetchOrStart(testutil.NewMockedOperation[sqliteCtx]("running", "target", "payload", "result", true))
+
+	testutil.AssertEqual(t, int64(0), reaped(repo.DeleteExpired(a.Finished, 2)))
+
+	clock.Advance(time.Hour)
+	testutil.AssertEqual(t, int64(2), reaped(repo.DeleteExpired(a.Finished, 2)))
+	testutil.AssertEqual(t, int64(1), reaped(repo.DeleteExpired(a.Finished, 2)))
+	testutil.AssertEqual(t, int64(0), reaped(repo.DeleteExpired(a.Finished, 2)))
+	testutil.AssertEqual(t, a.Running, repo.Lookup("running", "target").Status)
+
+	_, err := repo.DeleteExpired(a.TrackedOperationStatus(42), 2)
+	testutil.AssertContains(t, err.Error(), "Unknown tracked operation status")
+}
+
+func TestSqliteRepositoryFailExpiredStillRunning(t *testing.T) {
+	clock := a.NewFakeClock(time.Now())
+	reaped := affected(t)
+	repo := NewSqliteRepository[testutil.DebugPayload, testutil.DebugResult](newDatabase(t), WithClock(clock))
+
+	for _, key := range []string{"first", "second", "third"} {
+		repo.FetchOrStart(testutil.NewMockedOperation[sqliteCtx](key, "target", "payload", "result", true))
+	}
+
+	testutil.AssertEqual(t, int64(0), reaped(repo.FailExpiredStillRunning(2)))
+
+	clock.Advance(time.Hour)
+	testutil.AssertEqual(t, int64(2), reaped(repo.FailExpiredStillRunning(2)))
+	testutil.AssertEqual(t, int64(1), reaped(repo.FailExpiredStillRunning(2)))
+	testutil.AssertEqual(t, int64(0), reaped(repo.FailExpiredStillRunning(2)))
+
+	failedOperation := repo.Lookup("first", "target")
+	testutil.AssertEqual(t, a.Failed, failedOperation.Status)
+	testutil.AssertEqual(t, "Operation expired", failedOperation.Err.Error())
+}
+
+func TestSqliteRepositoryFailTimedOutStillRunning(t *testing.T) {
+	clock := a.NewFakeClock(time.Now())
+	reaped := affected(t)
+	repo := NewSqliteRepository[testutil.DebugPayload, testutil.DebugResult](newDatabase(t), WithClock(clock))
+
+	for _, key := range []string{"first", "second", "third"} {
+		repo.FetchOrStart(testutil.NewMockedOperation[sqliteCtx](key, "target", "payload", "result", true))
+	}
+
+	testutil.AssertEqual(t, int64(0), reaped(repo.FailTimedOutStillRunning(2)))
+
+	clock.Advance(2 * time.Minute)
+	testutil.AssertEqual(t, int64(2), reaped(repo.FailTimedOutStillRunning(2)))
+	testutil.AssertEqual(t, int64(1), reaped(repo.FailTimedOutStillRunning(2)))
+	testutil.AssertEqual(t, int64(0), reaped(repo.FailTimedOutStillRunning(2)))
+
+	failedOperation := repo.Lookup("first", "target")
+	testutil.AssertEqual(t, a.Failed, failedOperation.Status)
+	testutil.AssertEqual(t, "Operation timed out", failedOperation.Err.Error())
+}
+
+func TestMigrateTwice(t *testing.T) {
+	db := newDatabase(t)
+	testutil.AssertErrorNil(t, Migrate(context.Background(), db))
+
+	var count int64
+	db.QueryRow("SELECT COUNT(*) FROM tracked_operations_migrations;").Scan(&count)
+	testutil.AssertEqual(t, int64(1), count)
+}
+
+func TestSqliteRepositoryBusyDuplicate(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "ana.db"), 100*time.Millisecond)
+	testutil.AssertErrorNil(t, err)
+	t.Cleanup(func() { db.Close() })
+	testutil.AssertErrorNil(t, Migrate(context.Background(), db))
+
+	repo := NewSqliteRepository[testutil.DebugPayload, testutil.DebugResult](db)
+	manager := a.New[testutil.DebugPayload, testutil.DebugResult, sqliteCtx](repo)
+
+	started := make(chan struct{})
+	operation := a.NewOperation("key", "target", func(sqliteCtx) (*testutil.DebugResult, error) {
+		close(started)
+		time.Sleep(500 * time.Millisecond)
+		return &testutil.DebugResult{Value: "result"}, nil
+	}).WithPayload(&testutil.DebugPayload{Value: "payload"}).WithTimeout(time.Minute)
+
+	done := make(chan error)
+	go func() {
+		_, err := manager.Call(operation)
+		done <- err
+	}()
+	<-started
+
+	duplicate := testutil.NewMockedOperation[sqliteCtx]("key", "target", "payload", "result", true)
+	if _, err := manager.Call(duplicate); !errors.Is(err, a.ErrStillRunning) {
+		t.Fatalf("Expected to have still running error, but got \"%v\"", err)
+	}
+
+	unrelated := testutil.NewMockedOperation[sqliteCtx]("unrelated", "target", "payload", "result", true)
+	var sqliteErr sqlite3.Error
+	if _, err := manager.Call(unrelated); !errors.Is(err, a.ErrPanic) || !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrBusy {
+		t.Fatalf("Expected to have busy error, but got \"%v\"", err)
+	}
+
+	testutil.AssertErrorNil(t, <-done)
+}
+
+func TestSqliteRepositorySuite(t *testing.T) {
+	anatest.RunRepositorySuite(t, func(t *testing.T) *anatest.Backend[*SqliteContext[anatest.Payload, anatest.Result]] {
+		db := newDatabase(t)
+		if _, err := db.Exec("CREATE TABLE marks (value TEXT NOT NULL);"); err != nil {
+			t.Fatal(err)
+		}
+
+		return &anatest.Backend[*SqliteContext[anatest.Payload, anatest.Result]]{
+			Repository: NewSqliteRepository[anatest.Payload, anatest.Result](db),
+			Write: func(ctx *SqliteContext[anatest.Payload, anatest.Result], value string) error {
+				_, err := ctx.Tx.ExecContext(ctx.Context, "INSERT INTO marks (value) VALUES (?);", value)
+				return err
+			},
+			Written: func(value string) bool {
+				var count int64
+				db.QueryRow("SELECT COUNT(*) FROM marks WHERE value = ?;", value).Scan(&count)
+				return count > 0
+			},
+		}
+	})
+}
+
+func TestOpenEscapesPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ana ?#%.db")
+	db, err := Open(path, 0)
+	testutil.AssertErrorNil(t, err)
+	t.Cleanup(func() { db.Close() })
+	testutil.AssertErrorNil(t, Migrate(context.Background(), db))
+
+	_, err = os.Stat(path)
+	testutil.AssertErrorNil(t, err)
+}
+
+func newDatabase(t *testing.T, options ...Option) *sql.DB {
+	db, err := Open(filepath.Join(t.TempDir(), "ana.db"), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := Migrate(context.Background(), db, options...); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func affected(t *testing.T) func(int64, error) int64 {
+	return func(count int64, err error) int64 {
+		t.Helper()
+		testutil.AssertErrorNil(t, err)
+
+		return count
+	}
+}
+
+func tableExists(db *sql.DB, name string) bool {
+	var count int64
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;", name).Scan(&count)
+
+	return count > 0
+}
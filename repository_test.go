@@ -15,6 +15,14 @@ func (repo *emptyRepository) NewSession(operation Operation[mockedPayload, mocke
 	return NewSession(operation, newMockedCtx())
 }
 
+type panickingRepository struct {
+	emptyRepository
+}
+
+func (repo *panickingRepository) NewSession(Operation[mockedPayload, mockedResult, *mockedCtx]) *Session[mockedPayload, mockedResult, *mockedCtx] {
+	panic("database is down")
+}
+
 type trackedOperationRepository struct {
 	trackedOperation *TrackedOperation[mockedPayload, mockedResult]
 }
@@ -29,6 +37,52 @@ func (repo *trackedOperationRepository) FetchOrStart(operation Operation[mockedP
 	return repo.trackedOperation
 }
 
+func (repo *trackedOperationRepository) Lookup(key string, target string) *TrackedOperation[mockedPayload, mockedResult] {
+	if repo.trackedOperation == nil || repo.trackedOperation.Key != key || repo.trackedOperation.Target != target {
+		return nil
+	}
+
+	return repo.trackedOperation
+}
+
 func (repo *trackedOperationRepository) NewSession(operation Operation[mockedPayload, mockedResult, *mockedCtx]) *Session[mockedPayload, mockedResult, *mockedCtx] {
 	return NewSession(operation, newMockedCtx())
 }
+
+type batchRepository struct {
+	trackedOperations map[string]*TrackedOperation[mockedPayload, mockedResult]
+	batchCount        uint
+	fetchCount        uint
+}
+
+func newBatchRepository(trackedOperations ...*TrackedOperation[mockedPayload, mockedResult]) *batchRepository {
+	repo := &batchRepository{
+		trackedOperations: map[string]*TrackedOperation[mockedPayload, mockedResult]{},
+	}
+
+	for _, trackedOperation := range trackedOperations {
+		repo.trackedOperations[trackedOperation.Key] = trackedOperation
+	}
+
+	return repo
+}
+
+func (repo *batchRepository) FetchOrStart(operation Operation[mockedPayload, mockedResult, *mockedCtx]) *TrackedOperation[mockedPayload, mockedResult] {
+	repo.fetchCount += 1
+	return repo.trackedOperations[operation.Key()]
+}
+
+func (repo *batchRepository) FetchOrStartBatch(operations []Operation[mockedPayload, mockedResult, *mockedCtx]) []*TrackedOperation[mockedPayload, mockedResult] {
+	repo.batchCount += 1
+
+	trackedOperations := make([]*TrackedOperation[mockedPayload, mockedResult], len(operations))
+	for i, operation := range operations {
+		trackedOperations[i] = repo.trackedOperations[operation.Key()]
+	}
+
+	return trackedOperations
+}
+
+func (repo *batchRepository) NewSession(operation Operation[mockedPayload, mockedResult, *mockedCtx]) *Session[mockedPayload, mockedResult, *mockedCtx] {
+	return NewSession(operation, newMockedCtx())
+}
@@ -1,19 +1,44 @@
 package ana
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
+// SessionCtx records outcomes of operations. When a newer attempt took an
+// operation over in the meantime, Success and Fail roll back everything done by
+// this one and return a *SupersededError instead.
 type SessionCtx[P any, R any] interface {
-	Success(*TrackedOperation[P, R])
-	Fail(*TrackedOperation[P, R])
+	Success(*TrackedOperation[P, R]) error
+	Fail(*TrackedOperation[P, R]) error
+}
+
+// ExtendableSessionCtx is implemented by contexts able to extend the timeout of
+// a running operation. Sessions use it to keep heartbeating while operations run.
+type ExtendableSessionCtx interface {
+	Extend(time.Duration) error
+}
+
+// RetryableSessionCtx is implemented by contexts whose sessions may be run
+// again from scratch when they fail with some errors, such as serialization
+// failures. Abort discards everything done by a session without recording it.
+type RetryableSessionCtx interface {
+	ShouldRetry(err error, retries int) bool
+	Abort() error
 }
 
 // TODO: Add some tests at session_test.go
 type Session[P any, R any, C SessionCtx[P, R]] struct {
 	Context   C
+	recorder  SessionCtx[P, R]
 	operation Operation[P, R, C]
+	clock     Clock
 	startedAt time.Time
+	mutex     sync.Mutex
+	lease     time.Time
 	result    *R
 	err       error
+	panicked  *PanicError
 	closed    bool
 }
 
@@ -21,19 +46,91 @@ func NewSession[P any, R any, C SessionCtx[P, R]](operation Operation[P, R, C],
 	return &Session[P, R, C]{
 		Context:   context,
 		operation: operation,
+		clock:     SystemClock,
 		closed:    false,
 	}
 }
 
+// WithRecorder makes session record its outcome through recorder instead of its
+// context, so that repository wrappers may step in while outcomes are recorded.
+func (session *Session[P, R, C]) WithRecorder(recorder SessionCtx[P, R]) *Session[P, R, C] {
+	session.recorder = recorder
+	return session
+}
+
 func (session *Session[P, R, C]) call() {
 	defer session.recover()
-	session.startedAt = time.Now()
+	session.startedAt = session.clock.Now()
+	session.lease = session.startedAt.Add(session.operation.Timeout())
+
+	stop := session.heartbeat()
+	defer stop()
+
+	// Operations returning after their lease lapsed fail, and are recorded so,
+	// even if they succeeded, since they may have been taken over meanwhile.
 	session.result, session.err = session.operation.Call(session.Context)
+	if session.timedOut() {
+		session.result = nil
+		session.err = newTimeoutError(session.operation.Target(), session.operation.Key(), session.err)
+	}
+}
+
+func (session *Session[P, R, C]) timedOut() bool {
+	if session.operation.Timeout() == time.Duration(0) {
+		return false
+	}
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	return !session.clock.Now().Before(session.lease)
+}
+
+func (session *Session[P, R, C]) extend(timeout time.Duration) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	session.lease = session.clock.Now().Add(timeout)
+}
+
+// Extensions are best effort, so heartbeat never waits for an ongoing one to
+// return when stopped. Successful ones also push session lease forward.
+func (session *Session[P, R, C]) heartbeat() func() {
+	extendable, ok := any(session.Context).(ExtendableSessionCtx)
+	timeout := session.operation.Timeout()
+	if !ok || timeout == time.Duration(0) {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(timeout / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				select {
+				case <-done:
+					return
+				default:
+					if extendable.Extend(timeout) == nil {
+						session.extend(timeout)
+					}
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
 }
 
 func (session *Session[P, R, C]) recover() {
 	if recovery := recover(); recovery != nil {
-		session.err = newPanicError(recovery)
+		session.panicked = newPanicError(recovery)
+		session.err = session.panicked
 	}
 }
 
@@ -63,17 +160,65 @@ func (session *Session[P, R, C]) trackedOperation() *TrackedOperation[P, R] {
 	)
 }
 
-func (session *Session[P, R, C]) close() {
+// run calls operation and records its outcome, unless it fails with an error
+// its context tells to retry, in which case everything is discarded and it
+// returns true.
+func (session *Session[P, R, C]) run(retries int) bool {
+	defer session.close()
+
+	session.call()
+	if session.shouldRetry(session.err, retries) {
+		session.closed = true
+		return session.abort()
+	}
+
+	return session.shouldRetry(session.close(), retries)
+}
+
+func (session *Session[P, R, C]) shouldRetry(err error, retries int) bool {
+	retryable, ok := any(session.Context).(RetryableSessionCtx)
+	return ok && err != nil && retryable.ShouldRetry(err, retries)
+}
+
+// Sessions that could not be aborted are not retried, since their outcome
+// could still be recorded.
+func (session *Session[P, R, C]) abort() bool {
+	if err := any(session.Context).(RetryableSessionCtx).Abort(); err != nil {
+		session.result = nil
+		session.err = err
+		return false
+	}
+
+	return true
+}
+
+// close records operation outcome, returning the error that prevented it from
+// being recorded, if any.
+func (session *Session[P, R, C]) close() error {
 	if session.closed {
-		return
+		return nil
 	}
 
+	var recorder SessionCtx[P, R] = session.Context
+	if session.recorder != nil {
+		recorder = session.recorder
+	}
+
+	var err error
 	if session.err == nil {
-		session.Context.Success(session.trackedOperation())
-		session.closed = true
-		return
+		err = recorder.Success(session.trackedOperation())
+	} else {
+		err = recorder.Fail(session.trackedOperation())
+		if err != nil {
+			err = &failError{err: err, cause: session.err}
+		}
+	}
+
+	if err != nil {
+		session.result = nil
+		session.err = err
 	}
 
-	session.Context.Fail(session.trackedOperation())
 	session.closed = true
+	return err
 }
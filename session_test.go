@@ -1,6 +1,7 @@
 package ana
 
 import (
+	"errors"
 	"time"
 
 	"testing"
@@ -31,3 +32,183 @@ func TestSuccessfullSession(t *testing.T) {
 		t.Fatalf("Expected to not have called ctx.Fail once, but called %d times", ctx.FailCount)
 	}
 }
+
+func TestSessionRecorder(t *testing.T) {
+	operation := newMockedOperation(
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		time.Now(),
+		5*time.Second,
+		10*time.Second,
+		newMockedResultFn("result"),
+	)
+
+	ctx := newMockedCtx()
+	recorder := newMockedCtx()
+	session := NewSession(operation, ctx).WithRecorder(recorder)
+	session.call()
+	session.close()
+
+	if ctx.SuccessCount != 0 || recorder.SuccessCount != 1 {
+		t.Fatalf("Expected to have recorded through recorder only, but got %d and %d calls", ctx.SuccessCount, recorder.SuccessCount)
+	}
+}
+
+func TestSessionHeartbeat(t *testing.T) {
+	operation := newMockedOperation(
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		time.Now(),
+		15*time.Millisecond,
+		10*time.Second,
+		func() (*mockedResult, error) {
+			time.Sleep(50 * time.Millisecond)
+			return newMockedResult("result"), nil
+		},
+	)
+
+	ctx := newMockedCtx()
+	session := NewSession(operation, ctx)
+	session.call()
+	if err := session.close(); err != nil || session.err != nil {
+		t.Fatalf("Expected heartbeats to keep operation from timing out, but got \"%v\"", session.err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	extensions := ctx.ExtendCount.Load()
+	if extensions < 2 {
+		t.Fatalf("Expected to have extended at least twice, but extended %d times", extensions)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if ctx.ExtendCount.Load() != extensions {
+		t.Fatalf("Expected to not extend after operation returned, but extended %d times", ctx.ExtendCount.Load()-extensions)
+	}
+}
+
+func TestSessionWithoutTimeoutHeartbeat(t *testing.T) {
+	operation := newMockedOperation(
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		time.Now(),
+		time.Duration(0),
+		10*time.Second,
+		func() (*mockedResult, error) {
+			time.Sleep(20 * time.Millisecond)
+			return newMockedResult("result"), nil
+		},
+	)
+
+	ctx := newMockedCtx()
+	session := NewSession(operation, ctx)
+	session.call()
+	session.close()
+
+	if ctx.ExtendCount.Load() != 0 {
+		t.Fatalf("Expected to not have extended, but extended %d times", ctx.ExtendCount.Load())
+	}
+}
+
+func TestSupersededSession(t *testing.T) {
+	operation := newMockedOperation(
+		"key",
+		"target",
+		newMockedPayload("payload"),
+		time.Now(),
+		5*time.Second,
+		10*time.Second,
+		newMockedResultFn("result"),
+	)
+
+	ctx := newMockedCtx()
+	ctx.Err = NewSupersededError("target", "key")
+	session := NewSession(operation, ctx)
+	session.call()
+	session.close()
+
+	if session.result != nil {
+		t.Fatalf("Expected to have no result, but got \"%s\"", session.result.result)
+	}
+
+	if session.err != ctx.Err {
+		t.Fatalf("Expected to have \"%v\" error, but got \"%v\"", ctx.Err, session.err)
+	}
+}
+
+type retryableCtx struct {
+	*mockedCtx
+	AbortCount uint
+}
+
+func (ctx *retryableCtx) ShouldRetry(err error, retries int) bool {
+	return err.Error() == "conflict" && retries < 2
+}
+
+func (ctx *retryableCtx) Abort() error {
+	ctx.AbortCount += 1
+	return nil
+}
+
+type retryableRepository struct {
+	contexts []*retryableCtx
+}
+
+func (repo *retryableRepository) FetchOrStart(Operation[mockedPayload, mockedResult, *retryableCtx]) *TrackedOperation[mockedPayload, mockedResult] {
+	return nil
+}
+
+func (repo *retryableRepository) NewSession(operation Operation[mockedPayload, mockedResult, *retryableCtx]) *Session[mockedPayload, mockedResult, *retryableCtx] {
+	ctx := &retryableCtx{mockedCtx: newMockedCtx()}
+	repo.contexts = append(repo.contexts, ctx)
+
+	return NewSession(operation, ctx)
+}
+
+type conflictingOperation struct {
+	*mockedOperation
+	conflicts int
+	calls     int
+}
+
+func (operation *conflictingOperation) Call(*retryableCtx) (*mockedResult, error) {
+	operation.calls += 1
+	if operation.calls <= operation.conflicts {
+		return nil, errors.New("conflict")
+	}
+
+	return newMockedResult("result"), nil
+}
+
+func TestRetryableSession(t *testing.T) {
+	for _, conflicts := range []int{1, 3} {
+		repository := &retryableRepository{}
+		manager := New[mockedPayload, mockedResult, *retryableCtx](repository)
+		operation := &conflictingOperation{
+			mockedOperation: newMockedOperation("key", "target", newMockedPayload("payload"), time.Now(), 5*time.Second, 10*time.Second, nil),
+			conflicts:       conflicts,
+		}
+
+		result, err := manager.Call(operation)
+		if conflicts == 1 && (err != nil || result.result != "result") {
+			t.Fatalf("Expected to have \"result\" result, but got \"%v\" and \"%v\"", result, err)
+		}
+
+		if conflicts == 3 && (err == nil || err.Error() != "conflict") {
+			t.Fatalf("Expected to have \"conflict\" error, but got \"%v\"", err)
+		}
+
+		last := repository.contexts[len(repository.contexts)-1]
+		if len(repository.contexts) != min(conflicts, 2)+1 || last.AbortCount != 0 || last.SuccessCount+last.FailCount != 1 {
+			t.Fatalf("Expected to have retried %d times, but got %d sessions", min(conflicts, 2), len(repository.contexts))
+		}
+
+		for _, ctx := range repository.contexts[:len(repository.contexts)-1] {
+			if ctx.AbortCount != 1 || ctx.SuccessCount+ctx.FailCount != 0 {
+				t.Fatalf("Expected retried sessions to be aborted only")
+			}
+		}
+	}
+}
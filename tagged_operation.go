@@ -0,0 +1,164 @@
+package ana
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// TagDefaults holds what tagged operations get when their payloads have no
+// field tagged for it.
+type TagDefaults struct {
+	Timeout    time.Duration
+	Expiration time.Duration
+}
+
+type taggedFields struct {
+	target        string
+	key           []int
+	targetField   []int
+	referenceTime []int
+	timeout       []int
+	expiration    []int
+}
+
+type taggedFieldsEntry struct {
+	fields *taggedFields
+	err    error
+}
+
+var (
+	taggedFieldsCache sync.Map
+	durationType      = reflect.TypeOf(time.Duration(0))
+	timeType          = reflect.TypeOf(time.Time{})
+	stringType        = reflect.TypeOf("")
+)
+
+// NewTaggedOperation builds an operation calling fn from a payload struct whose
+// fields are tagged with:
+//
+//   - `ana:"key"`, a required string;
+//   - `ana:"target"`, a string defaulting to payload type name;
+//   - `ana:"reference_time"`, a time.Time defaulting, when empty, to when the
+//     operation is first called, as with NewOperation;
+//   - `ana:"timeout"` and `ana:"expiration"`, time.Duration values defaulting
+//     to given defaults when zero.
+//
+// Payload types are validated once, and their fields cached for later calls.
+func NewTaggedOperation[P any, R any, C SessionCtx[P, R]](
+	payload *P,
+	defaults TagDefaults,
+	fn func(C) (*R, error),
+) (*FuncOperation[P, R, C], error) {
+	fields, err := taggedFieldsOf(reflect.TypeOf(payload).Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	if payload == nil {
+		return nil, fmt.Errorf("Invalid tagged payload %v: it must not be nil.", reflect.TypeOf(payload).Elem())
+	}
+
+	value := reflect.ValueOf(payload).Elem()
+	key, _ := taggedValue[string](value, fields.key)
+	operation := NewOperation[P, R, C](key, fields.target, fn).
+		WithPayload(payload).
+		WithTimeout(defaults.Timeout).
+		WithExpiration(defaults.Expiration)
+
+	if target, ok := taggedValue[string](value, fields.targetField); ok && target != "" {
+		operation.WithTarget(target)
+	}
+
+	if referenceTime, ok := taggedValue[time.Time](value, fields.referenceTime); ok && !referenceTime.IsZero() {
+		operation.WithReferenceTime(referenceTime)
+	}
+
+	if timeout, ok := taggedValue[time.Duration](value, fields.timeout); ok && timeout != time.Duration(0) {
+		operation.WithTimeout(timeout)
+	}
+
+	if expiration, ok := taggedValue[time.Duration](value, fields.expiration); ok && expiration != time.Duration(0) {
+		operation.WithExpiration(expiration)
+	}
+
+	return operation, nil
+}
+
+func taggedValue[V any](value reflect.Value, index []int) (V, bool) {
+	var zero V
+	if index == nil {
+		return zero, false
+	}
+
+	field, err := value.FieldByIndexErr(index)
+	if err != nil {
+		return zero, false
+	}
+
+	return field.Interface().(V), true
+}
+
+func taggedFieldsOf(payloadType reflect.Type) (*taggedFields, error) {
+	if cached, ok := taggedFieldsCache.Load(payloadType); ok {
+		entry := cached.(*taggedFieldsEntry)
+		return entry.fields, entry.err
+	}
+
+	fields, err := parseTaggedFields(payloadType)
+	taggedFieldsCache.Store(payloadType, &taggedFieldsEntry{fields, err})
+
+	return fields, err
+}
+
+func parseTaggedFields(payloadType reflect.Type) (*taggedFields, error) {
+	if payloadType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Invalid tagged payload %v: it must be a struct.", payloadType)
+	}
+
+	fields := &taggedFields{target: payloadType.Name()}
+	for _, field := range reflect.VisibleFields(payloadType) {
+		tag, ok := field.Tag.Lookup("ana")
+		if !ok {
+			continue
+		}
+
+		var index *[]int
+		var fieldType reflect.Type
+		switch tag {
+		case "key":
+			index, fieldType = &fields.key, stringType
+		case "target":
+			index, fieldType = &fields.targetField, stringType
+		case "reference_time":
+			index, fieldType = &fields.referenceTime, timeType
+		case "timeout":
+			index, fieldType = &fields.timeout, durationType
+		case "expiration":
+			index, fieldType = &fields.expiration, durationType
+		default:
+			return nil, fmt.Errorf("Invalid tagged payload %v: unknown tag %q on %v field.", payloadType, tag, field.Name)
+		}
+
+		if *index != nil {
+			return nil, fmt.Errorf("Invalid tagged payload %v: more than one field tagged %q.", payloadType, tag)
+		}
+
+		if !field.IsExported() {
+			return nil, fmt.Errorf("Invalid tagged payload %v: %v field tagged %q must be exported.", payloadType, field.Name, tag)
+		}
+
+		if field.Type != fieldType {
+			return nil, fmt.Errorf("Invalid tagged payload %v: %v field tagged %q must be %v.", payloadType, field.Name, tag, fieldType)
+		}
+
+		*index = field.Index
+	}
+
+	if fields.key == nil {
+		return nil, fmt.Errorf("Invalid tagged payload %v: no field tagged \"key\".", payloadType)
+	}
+
+	return fields, nil
+}
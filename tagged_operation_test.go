@@ -0,0 +1,103 @@
+package ana
+
+import (
+	"time"
+
+	"testing"
+)
+
+type noopCtx[P any] struct{}
+
+func (ctx *noopCtx[P]) Success(*TrackedOperation[P, mockedResult]) error { return nil }
+func (ctx *noopCtx[P]) Fail(*TrackedOperation[P, mockedResult]) error    { return nil }
+
+type requestMeta struct {
+	RequestId string    `ana:"key"`
+	CreatedAt time.Time `ana:"reference_time"`
+}
+
+type chargePayload struct {
+	requestMeta
+	Amount  int
+	Timeout time.Duration `ana:"timeout"`
+}
+
+type routedPayload struct {
+	Id    string `ana:"key"`
+	Route string `ana:"target"`
+}
+
+func TestNewTaggedOperation(t *testing.T) {
+	createdAt := time.Now().Add(-time.Minute)
+	payload := &chargePayload{requestMeta: requestMeta{"request", createdAt}, Amount: 10}
+	defaults := TagDefaults{Timeout: 5 * time.Second, Expiration: time.Hour}
+
+	operation, err := NewTaggedOperation(payload, defaults, func(*noopCtx[chargePayload]) (*mockedResult, error) {
+		return newMockedResult("result"), nil
+	})
+	if err != nil {
+		t.Fatalf("Expected to have no error, but got \"%v\"", err)
+	}
+
+	if operation.Key() != "request" || operation.Target() != "chargePayload" || operation.Payload() != payload {
+		t.Fatalf("Expected to have tagged key and type name as target, but got \"%s\" and \"%s\"", operation.Key(), operation.Target())
+	}
+
+	if !operation.ReferenceTime().Equal(createdAt) || operation.Timeout() != 5*time.Second || operation.Expiration() != time.Hour {
+		t.Fatalf("Expected to have tagged reference time and defaults, but got \"%v\", \"%v\" and \"%v\"", operation.ReferenceTime(), operation.Timeout(), operation.Expiration())
+	}
+
+	payload.Timeout = time.Second
+	operation, _ = NewTaggedOperation(payload, defaults, func(*noopCtx[chargePayload]) (*mockedResult, error) { return nil, nil })
+	if operation.Timeout() != time.Second {
+		t.Fatalf("Expected to have tagged timeout, but got \"%v\"", operation.Timeout())
+	}
+
+	routed, _ := NewTaggedOperation(&routedPayload{"id", "route"}, defaults, func(*noopCtx[routedPayload]) (*mockedResult, error) { return nil, nil })
+	if routed.Key() != "id" || routed.Target() != "route" {
+		t.Fatalf("Expected to have tagged key and target, but got \"%s\" and \"%s\"", routed.Key(), routed.Target())
+	}
+}
+
+type untaggedPayload struct {
+	Id string
+}
+
+type wrongTypePayload struct {
+	Id        string `ana:"key"`
+	CreatedAt string `ana:"reference_time"`
+}
+
+type unknownTagPayload struct {
+	Id string `ana:"identifier"`
+}
+
+type duplicatedTagPayload struct {
+	Id      string `ana:"key"`
+	OtherId string `ana:"key"`
+}
+
+func TestInvalidTaggedPayloads(t *testing.T) {
+	errs := []error{
+		taggedPayloadErr(&untaggedPayload{}),
+		taggedPayloadErr(&wrongTypePayload{}),
+		taggedPayloadErr(&unknownTagPayload{}),
+		taggedPayloadErr(&duplicatedTagPayload{}),
+		taggedPayloadErr[routedPayload](nil),
+	}
+
+	for i, err := range errs {
+		if err == nil {
+			t.Fatalf("Expected payload %d to be invalid", i)
+		}
+	}
+
+	if cachedErr := taggedPayloadErr(&untaggedPayload{}); cachedErr != errs[0] {
+		t.Fatalf("Expected validation to be cached, but got \"%v\"", cachedErr)
+	}
+}
+
+func taggedPayloadErr[P any](payload *P) error {
+	_, err := NewTaggedOperation(payload, TagDefaults{}, func(*noopCtx[P]) (*mockedResult, error) { return nil, nil })
+	return err
+}
@@ -54,10 +54,14 @@ func (operation *TrackedOperation[P, R]) isFinished() bool {
 	return operation.Status == Finished
 }
 
-func (operation *TrackedOperation[P, R]) isExpired() bool {
-	return operation.Expiration != time.Time{} && time.Now().After(operation.Expiration)
+func (operation *TrackedOperation[P, R]) isFailed() bool {
+	return operation.Status == Failed
 }
 
-func (operation *TrackedOperation[P, R]) stillRunning() bool {
-	return operation.Status == Running && (operation.Timeout == time.Time{} || operation.Timeout.After(time.Now()))
+func (operation *TrackedOperation[P, R]) isExpired(now time.Time) bool {
+	return operation.Expiration != time.Time{} && now.After(operation.Expiration)
+}
+
+func (operation *TrackedOperation[P, R]) stillRunning(now time.Time) bool {
+	return operation.Status == Running && (operation.Timeout == time.Time{} || operation.Timeout.After(now))
 }
@@ -1,6 +1,7 @@
 package fiber
 
 import (
+	"errors"
 	"time"
 
 	a "github.com/dalthon/ana"
@@ -25,6 +26,10 @@ type Config struct {
 	ReferenceTime func(*f.Ctx) time.Time
 	Timeout       func(*f.Ctx) time.Duration
 	Expiration    func(*f.Ctx) time.Duration
+
+	// KeyPolicy validates keys before they reach manager, which still applies
+	// its own policy afterwards.
+	KeyPolicy *a.KeyPolicy
 }
 
 func Value[V any](value V) func(*f.Ctx) V {
@@ -55,8 +60,22 @@ func (middleware *Middleware[C]) Call(idempotentHandler func(*f.Ctx, C) (*HttpRe
 	return func(c *f.Ctx) error {
 		operation := newHttpOperation(c, idempotentHandler, config, middleware.config)
 
+		if policy := coalesceKeyPolicy(config, middleware.config); policy != nil {
+			key, err := policy.Normalize(operation.key)
+			if err != nil {
+				return f.NewError(f.StatusBadRequest, err.Error())
+			}
+
+			operation.key = key
+		}
+
 		result, err := middleware.ana.Call(operation)
 		if err != nil {
+			var invalidKeyErr *a.InvalidKeyError
+			if errors.As(err, &invalidKeyErr) {
+				return f.NewError(f.StatusBadRequest, err.Error())
+			}
+
 			return err
 		}
 
@@ -64,3 +83,11 @@ func (middleware *Middleware[C]) Call(idempotentHandler func(*f.Ctx, C) (*HttpRe
 		return nil
 	}
 }
+
+func coalesceKeyPolicy(specificConfig, sharedConfig *Config) *a.KeyPolicy {
+	if specificConfig.KeyPolicy != nil {
+		return specificConfig.KeyPolicy
+	}
+
+	return sharedConfig.KeyPolicy
+}
@@ -0,0 +1,80 @@
+package fiber
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	a "github.com/dalthon/ana"
+	"github.com/dalthon/ana/anatest"
+	f "github.com/gofiber/fiber/v2"
+
+	"testing"
+)
+
+type fakeCtx = *anatest.FakeContext[HttpPayload, HttpResponse]
+
+func TestMiddlewareRejectedKey(t *testing.T) {
+	app, _, calls := newApp(&Config{KeyPolicy: &a.KeyPolicy{Format: a.UUIDKeyFormat}})
+
+	assertResponse(t, app, "not-an-uuid", f.StatusBadRequest, "key is not an UUID")
+
+	defaultApp, _, defaultCalls := newApp(&Config{})
+	assertResponse(t, defaultApp, strings.Repeat("k", a.DefaultKeyMaxLength+1), f.StatusBadRequest, "key is too long")
+
+	if *calls != 0 || *defaultCalls != 0 {
+		t.Fatalf("Expected handlers not to be called, but they were called %d and %d times", *calls, *defaultCalls)
+	}
+}
+
+func TestMiddlewareNormalizedKey(t *testing.T) {
+	app, repository, calls := newApp(&Config{KeyPolicy: &a.KeyPolicy{Format: a.UUIDKeyFormat, Case: a.LowerKeyCase}})
+
+	assertResponse(t, app, "6BA7B810-9DAD-11D1-80B4-00C04FD430C8", f.StatusCreated, "charged")
+	assertResponse(t, app, "6ba7b810-9dad-11d1-80b4-00c04fd430c8", f.StatusCreated, "charged")
+
+	if *calls != 1 {
+		t.Fatalf("Expected handler to be called once, but it was called %d times", *calls)
+	}
+
+	trackedOperation := repository.Lookup("6ba7b810-9dad-11d1-80b4-00c04fd430c8", "[POST]/charges")
+	if trackedOperation == nil || trackedOperation.Status != a.Finished {
+		t.Fatalf("Expected to have operation tracked by normalized key, but got %+v", trackedOperation)
+	}
+}
+
+func newApp(config *Config) (*f.App, *anatest.FakeRepository[HttpPayload, HttpResponse], *int) {
+	repository := anatest.NewFakeRepository[HttpPayload, HttpResponse](a.SystemClock)
+	manager := a.New[HttpPayload, HttpResponse, fakeCtx](repository)
+
+	config.ReferenceTime = Value(time.Now())
+	config.Timeout = Value(time.Minute)
+	config.Expiration = Value(time.Hour)
+
+	calls := 0
+	app := f.New()
+	app.Post("/charges", New(manager, config).Call(func(*f.Ctx, fakeCtx) (*HttpResponse, error) {
+		calls += 1
+		return &HttpResponse{Status: f.StatusCreated, Body: "charged"}, nil
+	}, nil))
+
+	return app, repository, &calls
+}
+
+func assertResponse(t *testing.T, app *f.App, key string, status int, body string) {
+	t.Helper()
+
+	request := httptest.NewRequest("POST", "/charges", nil)
+	request.Header.Set("X-Idempotency-Key", key)
+
+	response, err := app.Test(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responseBody, _ := io.ReadAll(response.Body)
+	if response.StatusCode != status || !strings.Contains(string(responseBody), body) {
+		t.Fatalf("Expected to have %d response with \"%s\", but got %d with \"%s\"", status, body, response.StatusCode, responseBody)
+	}
+}